@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/compile"
+	"gitlab.com/coalang/go-coa/try2/encode"
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// cmdBuild compiles a source file into a .coab file.
+func cmdBuild(args []string) (err error) {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	var out string
+	var allowParallel bool
+	fs.StringVar(&out, "o", "", "path of compiled output (default: source path with .coab extension)")
+	fs.BoolVar(&allowParallel, "parallel", true, "compile blocks to run in parallel")
+	addErrorFormatFlag(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: build [-o out.coab] [-parallel] [-error-format text|json] file.coa")
+	}
+	path := fs.Arg(0)
+	if out == "" {
+		out = strings.TrimSuffix(path, filepath.Ext(path)) + ".coab"
+	}
+
+	p, err := compileFile(path, allowParallel)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err2 := f.Close()
+		if err == nil {
+			err = err2
+		}
+	}()
+	return encode.Encode(f, p)
+}
+
+// compileFile parses and compiles the source file at path.
+func compileFile(path string, allowParallel bool) (*encode.Program, error) {
+	env := parser.NewEnv(lexer.Position{Filename: "root"}, false)
+	root, err := env.LoadPathOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	ce := compile.NewEnv(lexer.Position{Filename: "root"})
+	ce.Parallel = allowParallel
+	insts, err := ce.NewScope().CompileNodes(*root)
+	if err != nil {
+		return nil, err
+	}
+	return &encode.Program{Env: ce, Insts: insts}, nil
+}
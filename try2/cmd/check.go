@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// cmdCheck checks the types of calls in source files without running them (see parser.TypeCheck).
+func cmdCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	addErrorFormatFlag(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: check [-error-format text|json] file.coa...")
+	}
+
+	var all errs.Errors
+	for _, path := range fs.Args() {
+		env := parser.NewEnv(lexer.Position{Filename: "root"}, false)
+		root, err := env.LoadPathOnly(path)
+		if err != nil {
+			return err
+		}
+		err = parser.TypeCheck(root)
+		var errs2 errs.Errors
+		if !errors.As(err, &errs2) {
+			continue
+		}
+		all = append(all, errs2...)
+	}
+	if len(all) != 0 {
+		// each error is reported by main
+		return all
+	}
+	return nil
+}
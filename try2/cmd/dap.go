@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"gitlab.com/coalang/go-coa/try2/dap"
+)
+
+// cmdDap runs a debug adapter over stdin and stdout.
+func cmdDap(args []string) error {
+	fs := flag.NewFlagSet("dap", flag.ExitOnError)
+	var verbose bool
+	fs.BoolVar(&verbose, "v", false, "log to stderr")
+	_ = fs.Parse(args)
+	if !verbose {
+		log.SetOutput(io.Discard)
+	}
+	return dap.NewServer().Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+	"gitlab.com/coalang/go-coa/try2/vm"
+)
+
+const debugHelp = `c, continue   run until the next breakpoint
+s, step       run until the next line, stepping into calls
+n, next       run until the next line, stepping over calls
+o, out        run until the current call returns
+b [file:]line add a breakpoint
+d [file:]line delete a breakpoint
+l, list       list breakpoints
+p, print      show the variables, arguments and stack of the current scope
+e, eval src   evaluate src in the current scope
+bt            show the scopes, latest first
+q, quit       stop running
+h, help       show this`
+
+// cmdDebug runs a program in the VM, pausing at breakpoints and on entry for
+// commands read from stdin (see debugHelp).
+func cmdDebug(args []string) error {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	var allowParallel, verbose bool
+	var breaks []string
+	fs.BoolVar(&allowParallel, "parallel", false, "allow parallel evaluation (strands pause one at a time)")
+	fs.BoolVar(&verbose, "v", false, "log execution")
+	fs.Func("break", "add a breakpoint at [file:]line (may be repeated)", func(s string) error {
+		breaks = append(breaks, s)
+		return nil
+	})
+	_ = fs.Parse(args)
+	if !verbose {
+		log.SetOutput(io.Discard)
+	}
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return errors.New("usage: debug [-parallel] [-v] [-break [file:]line]... file.coa|file.coab [args...]")
+	}
+	path, rest := rest[0], rest[1:]
+	if len(rest) != 0 {
+		parser.OsArgs = append([]string{path}, rest...)
+	}
+
+	p, err := loadProgram(path, allowParallel)
+	if err != nil {
+		return err
+	}
+	stdio := &parser.Stdio{In: os.Stdin, Out: os.Stdout, Err: os.Stderr}
+	d := &debugger{path: path, in: stdio.Reader(), out: os.Stdout, sources: map[string][]string{}}
+	d.d = parser.NewDebugger(d.onStop)
+	for _, s := range breaks {
+		b, err := d.parseBreakpoint(s)
+		if err != nil {
+			return fmt.Errorf("-break: %w", err)
+		}
+		d.d.AddBreakpoint(b)
+	}
+	d.d.Pause()
+
+	v := vm.NewVM()
+	v.Stdio = stdio
+	v.Debugger = d.d
+	err = v.Execute(vm.NewProgram(p.Insts))
+	if errors.Is(err, parser.ErrQuit) {
+		return nil
+	}
+	return err
+}
+
+type debugger struct {
+	d    *parser.Debugger
+	path string
+	// path is the file of breakpoints without one.
+	in      *bufio.Reader
+	out     io.Writer
+	sources map[string][]string
+	// sources are the lines of files shown so far.
+}
+
+// onStop shows where p stopped, and reads commands until one continues.
+func (d *debugger) onStop(p parser.Paused, stop parser.Stop) parser.Action {
+	fmt.Fprintf(d.out, "%s (%s)\n", stop.Pos, stop.Reason)
+	if line, ok := d.sourceLine(stop.Pos.Filename, stop.Pos.Line); ok {
+		fmt.Fprintf(d.out, "%5d\t%s\n", stop.Pos.Line, line)
+	}
+	for {
+		fmt.Fprint(d.out, "debug> ")
+		line, err := d.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(d.out)
+			return parser.ActionQuit
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "c", "continue":
+			return parser.ActionContinue
+		case "s", "step":
+			return parser.ActionStepInto
+		case "n", "next":
+			return parser.ActionStepOver
+		case "o", "out":
+			return parser.ActionStepOut
+		case "q", "quit":
+			return parser.ActionQuit
+		case "b", "d":
+			if len(fields) != 2 {
+				fmt.Fprintf(d.out, "usage: %s [file:]line\n", fields[0])
+				continue
+			}
+			b, err := d.parseBreakpoint(fields[1])
+			if err != nil {
+				fmt.Fprintln(d.out, err)
+				continue
+			}
+			if fields[0] == "b" {
+				d.d.AddBreakpoint(b)
+			} else {
+				d.d.RemoveBreakpoint(b)
+			}
+		case "l", "list":
+			for _, b := range d.d.Breakpoints() {
+				fmt.Fprintf(d.out, "%s:%d\n", b.Filename, b.Line)
+			}
+		case "p", "print":
+			d.printFrame(p.Frames()[0])
+		case "bt":
+			for i, f := range p.Frames() {
+				fmt.Fprintf(d.out, "%3d: %s (%s)\n", i, f.Pos, f.Name)
+			}
+		case "e", "eval":
+			src := strings.TrimSpace(strings.TrimSpace(line)[len(fields[0]):])
+			result, err := p.Eval(0, src)
+			if err != nil {
+				fmt.Fprintln(d.out, err)
+				continue
+			}
+			fmt.Fprintln(d.out, inspectValue(result))
+		case "h", "help":
+			fmt.Fprintln(d.out, debugHelp)
+		default:
+			fmt.Fprintf(d.out, "unknown command %s (h for help)\n", fields[0])
+		}
+	}
+}
+
+func (d *debugger) printFrame(f parser.Frame) {
+	fmt.Fprintf(d.out, "%s (%s)\n", f.Pos, f.Name)
+	for _, s := range f.Scopes {
+		fmt.Fprintf(d.out, "%s:\n", s.Name)
+		for _, v := range s.Vars {
+			fmt.Fprintf(d.out, "  %s = %s\n", v.Name, inspectValue(v.Value))
+		}
+	}
+}
+
+func inspectValue(evaler parser.Evaler) string {
+	if evaler == nil {
+		return "(unset)"
+	}
+	// Instructions are shown with their snapshot on the next lines
+	return strings.TrimSpace(util.ToInspect(evaler))
+}
+
+// parseBreakpoint parses a breakpoint in the format [file:]line.
+func (d *debugger) parseBreakpoint(s string) (parser.Breakpoint, error) {
+	file, lineS := d.path, s
+	if i := strings.LastIndex(s, ":"); i != -1 {
+		file, lineS = s[:i], s[i+1:]
+	}
+	line, err := strconv.Atoi(lineS)
+	if err != nil || line < 1 {
+		return parser.Breakpoint{}, fmt.Errorf("invalid breakpoint %s: want [file:]line", s)
+	}
+	return parser.Breakpoint{Filename: file, Line: line}, nil
+}
+
+// sourceLine returns the line numbered line of the file at path.
+func (d *debugger) sourceLine(path string, line int) (string, bool) {
+	lines, ok := d.sources[path]
+	if !ok {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		d.sources[path] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return lines[line-1], true
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gitlab.com/coalang/go-coa/try2/format"
+)
+
+// cmdFmt formats source files (see package format).
+func cmdFmt(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	var check, write bool
+	fs.BoolVar(&check, "check", false, "only list files that are not formatted, and fail if there are any")
+	fs.BoolVar(&write, "w", false, "write the result to the files instead of stdout")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: fmt [-check] [-w] file.coa...")
+	}
+
+	unformatted := 0
+	for _, path := range fs.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		formatted, err := format.Source(path, src)
+		if err != nil {
+			return err
+		}
+		switch {
+		case check:
+			if string(formatted) != string(src) {
+				fmt.Println(path)
+				unformatted++
+			}
+		case write:
+			if string(formatted) == string(src) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			err = os.WriteFile(path, formatted, info.Mode())
+			if err != nil {
+				return err
+			}
+		default:
+			_, err = os.Stdout.Write(formatted)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if unformatted != 0 {
+		return fmt.Errorf("%d file(s) not formatted", unformatted)
+	}
+	return nil
+}
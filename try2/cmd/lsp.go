@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"gitlab.com/coalang/go-coa/try2/lsp"
+)
+
+// cmdLsp runs a language server over stdin and stdout.
+func cmdLsp(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	var builtin string
+	var verbose bool
+	fs.StringVar(&builtin, "builtin", "", "path of the builtin docs shown on hover, instead of the ones built in")
+	fs.BoolVar(&verbose, "v", false, "log to stderr")
+	_ = fs.Parse(args)
+	if !verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	docs := lsp.BuiltinDocs()
+	if builtin != "" {
+		src, err := os.ReadFile(builtin)
+		if err != nil {
+			return err
+		}
+		docs = lsp.ParseDocs(string(src))
+	}
+	return lsp.NewServer(docs).Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/repl"
+)
+
+// cmdRepl reads, evaluates and prints Coa code interactively (see package repl).
+func cmdRepl(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	var allowParallel, verbose bool
+	var policy string
+	fs.BoolVar(&allowParallel, "parallel", true, "allow parallel evaluation")
+	fs.BoolVar(&verbose, "v", false, "log evaluation")
+	fs.StringVar(&policy, "policy", "", "path of policy file restricting resources (see parser.ParsePolicy)")
+	_ = fs.Parse(args)
+	if !verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	env := parser.NewEnv(lexer.Position{Filename: "repl"}, allowParallel)
+	if policy != "" {
+		rg, err := parser.LoadPolicy(policy)
+		if err != nil {
+			return err
+		}
+		env.ResourcesGuard = rg
+	}
+	return repl.New(env, os.Stdout).Run(os.Stdin)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gitlab.com/coalang/go-coa/try2/errs"
+)
+
+// errorFormat is the format main writes the error of a command in: "text" or
+// "json" (see errs.WriteJSON).
+var errorFormat = "text"
+
+// addErrorFormatFlag adds the -error-format flag setting errorFormat to fs.
+func addErrorFormatFlag(fs *flag.FlagSet) {
+	fs.Func("error-format", "format of errors: text or json (default text)", func(s string) error {
+		if s != "text" && s != "json" {
+			return fmt.Errorf("unknown format %s: want text or json", s)
+		}
+		errorFormat = s
+		return nil
+	})
+}
+
+// printError writes err to f in errorFormat. Text is colored if f is a terminal.
+func printError(f *os.File, err error) {
+	if errorFormat == "json" {
+		_ = errs.WriteJSON(f, err)
+		return
+	}
+	r := &errs.Renderer{Color: isTerminal(f), ReadFile: os.ReadFile}
+	_ = r.Render(f, err)
+}
+
+// isTerminal returns true if f is a terminal which should be colored (see
+// https://no-color.org).
+func isTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"gitlab.com/coalang/go-coa/try2/encode"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/prof"
+	"gitlab.com/coalang/go-coa/try2/trace"
+	"gitlab.com/coalang/go-coa/try2/vm"
+)
+
+// cmdRun runs a source file or a program compiled by cmdBuild.
+func cmdRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var path, policy, profile, traceOut string
+	var allowParallel, deterministic bool
+	var seed int64
+	var timeout time.Duration
+	var limits parser.Limits
+	fs.StringVar(&path, "path", "", "path of file to run")
+	fs.BoolVar(&allowParallel, "parallel", true, "allow parallel evaluation")
+	fs.StringVar(&policy, "policy", "", "path of policy file restricting resources (see parser.ParsePolicy)")
+	fs.StringVar(&profile, "profile", "", "path to write a pprof profile of calls to (see go tool pprof)")
+	fs.StringVar(&traceOut, "trace", "", "path to write a Chrome trace of the scheduling of strands to")
+	fs.BoolVar(&deterministic, "deterministic", false, "run strands one at a time in an order picked by -seed, with a virtual clock")
+	fs.Int64Var(&seed, "seed", 0, "seed of -deterministic")
+	fs.DurationVar(&timeout, "timeout", 0, "stop running after this long (0 for no limit)")
+	fs.Int64Var(&limits.Instructions, "max-instructions", 0, "maximum number of instructions to execute (0 for no limit)")
+	fs.IntVar(&limits.CallDepth, "max-depth", 0, "maximum call depth (0 for no limit)")
+	fs.IntVar(&limits.ListLen, "max-list", 0, "maximum length of lists (0 for no limit)")
+	fs.IntVar(&limits.StringSize, "max-string", 0, "maximum size of strings in bytes (0 for no limit)")
+	addErrorFormatFlag(fs)
+	_ = fs.Parse(args)
+	rest := fs.Args()
+	if path == "" {
+		if len(rest) == 0 {
+			return errors.New("usage: run [-parallel] [-policy file] [-profile file] [-trace file] [-deterministic] [-seed n] [-timeout duration] [-max-instructions n] [-max-depth n] [-max-list n] [-max-string n] [-error-format text|json] file.coa|file.coab [args...]")
+		}
+		path, rest = rest[0], rest[1:]
+	}
+
+	if len(rest) != 0 {
+		parser.OsArgs = append([]string{path}, rest...)
+	}
+
+	p, err := loadProgram(path, allowParallel)
+	if err != nil {
+		return err
+	}
+	log.Printf("instructions:\n%s", p.Insts)
+
+	v := vm.NewVM()
+	v.Limits = &limits
+	if deterministic {
+		v.Deterministic = parser.NewDeterministic(seed)
+	}
+	if policy != "" {
+		v.ResourcesGuard, err = parser.LoadPolicy(policy)
+		if err != nil {
+			return err
+		}
+	}
+	ctx := context.Background()
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if profile != "" {
+		v.Profile = prof.New()
+	}
+	if traceOut != "" {
+		v.Tracer = trace.New()
+	}
+	err = v.ExecuteContext(ctx, vm.NewProgram(p.Insts))
+	// the profile and trace are useful even if executing failed
+	if profile != "" {
+		if err2 := writeProfile(profile, v.Profile); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	if traceOut != "" {
+		if err2 := writeTrace(traceOut, v.Tracer); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// writeProfile writes p to path as a pprof profile.
+func writeProfile(path string, p *prof.Profile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = p.WritePprof(f)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// loadProgram decodes path if it is a compiled program, and compiles it otherwise.
+func loadProgram(path string, allowParallel bool) (*encode.Program, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if encode.IsEncoded(data) {
+		return encode.Decode(bytes.NewReader(data))
+	}
+	return compileFile(path, allowParallel)
+}
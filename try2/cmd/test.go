@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/trace"
+)
+
+// cmdTest runs the tests defined by (@test name block) in *_test.coa files.
+func cmdTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	var run, junit, traceOut string
+	var allowParallel, verbose, logEval, deterministic bool
+	var seed int64
+	fs.StringVar(&run, "run", "", "only run tests whose names match this regexp")
+	fs.StringVar(&junit, "junit", "", "path to write a JUnit XML report to")
+	fs.StringVar(&traceOut, "trace", "", "path to write a Chrome trace of the evaluation to")
+	fs.BoolVar(&allowParallel, "parallel", true, "allow parallel evaluation")
+	fs.BoolVar(&deterministic, "deterministic", false, "run strands one at a time in an order picked by -seed, with a virtual clock")
+	fs.Int64Var(&seed, "seed", 0, "seed of -deterministic")
+	fs.BoolVar(&verbose, "v", false, "list passed tests too")
+	fs.BoolVar(&logEval, "log", false, "log evaluation")
+	_ = fs.Parse(args)
+	if !logEval {
+		log.SetOutput(io.Discard)
+	}
+	var filter *regexp.Regexp
+	if run != "" {
+		var err error
+		filter, err = regexp.Compile(run)
+		if err != nil {
+			return fmt.Errorf("-run: %w", err)
+		}
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := findTestFiles(paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no *_test.coa files found")
+	}
+
+	var tracer *trace.Tracer
+	if traceOut != "" {
+		tracer = trace.New()
+	}
+	suites := make([]*testSuite, len(files))
+	failed := 0
+	for i, path := range files {
+		suites[i] = &testSuite{path: path, filter: filter, verbose: verbose, out: os.Stdout, tracer: tracer}
+		if deterministic {
+			// each file gets the same seed, so that it can be replayed alone
+			suites[i].deterministic = parser.NewDeterministic(seed)
+		}
+		suites[i].run(allowParallel)
+		failed += suites[i].failed()
+	}
+	if tracer != nil {
+		err = writeTrace(traceOut, tracer)
+		if err != nil {
+			return err
+		}
+	}
+	if junit != "" {
+		err = writeJUnit(junit, suites)
+		if err != nil {
+			return err
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d test(s) failed", failed)
+	}
+	return nil
+}
+
+// writeTrace writes the events of t to path as a Chrome trace.
+func writeTrace(path string, t *trace.Tracer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = t.WriteJSON(f)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// findTestFiles returns the paths of *_test.coa files in paths, which are files or
+// directories to search recursively.
+func findTestFiles(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, "_test.coa") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// testSuite is the tests of a file.
+type testSuite struct {
+	path    string
+	filter  *regexp.Regexp
+	verbose bool
+	out     io.Writer
+	tracer  *trace.Tracer
+
+	deterministic *parser.Deterministic
+
+	lock    sync.Mutex
+	results []testResult
+	err     error
+	// err is the error of the file outside of tests.
+	time time.Duration
+}
+
+var _ parser.Tester = new(testSuite)
+
+type testResult struct {
+	name string
+	pos  lexer.Position
+	err  error
+	time time.Duration
+}
+
+func (s *testSuite) run(allowParallel bool) {
+	start := time.Now()
+	env := parser.NewEnv(lexer.Position{Filename: s.path}, allowParallel)
+	env.Tester = s
+	env.Tracer = s.tracer
+	env.Deterministic = s.deterministic
+	_, s.err = env.LoadPath(s.path)
+	s.time = time.Since(start)
+	if s.err != nil {
+		fmt.Fprintf(s.out, "%s\n", s.err)
+	}
+	status := "ok  "
+	if s.failed() != 0 {
+		status = "FAIL"
+	}
+	fmt.Fprintf(s.out, "%s\t%s\t%.3fs\n", status, s.path, s.time.Seconds())
+}
+
+func (s *testSuite) Test(name string, pos lexer.Position, run func() error) bool {
+	if s.filter != nil && !s.filter.MatchString(name) {
+		return true
+	}
+	start := time.Now()
+	err := run()
+	r := testResult{name: name, pos: pos, err: err, time: time.Since(start)}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.results = append(s.results, r)
+	switch {
+	case err != nil:
+		fmt.Fprintf(s.out, "--- FAIL: %s (%.2fs)\n    %s: %s\n", name, r.time.Seconds(), r.failurePos(), errs.Message(err))
+	case s.verbose:
+		fmt.Fprintf(s.out, "--- PASS: %s (%.2fs)\n", name, r.time.Seconds())
+	}
+	return err == nil
+}
+
+// failed returns the number of failed tests, counting an error outside of tests
+// as one.
+func (s *testSuite) failed() int {
+	n := 0
+	if s.err != nil {
+		n++
+	}
+	for _, r := range s.results {
+		if r.err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// failurePos returns the position of the innermost call in the trace of the
+// error of r, or of the test if there is no trace.
+func (r testResult) failurePos() lexer.Position {
+	var ert *errs.ERT
+	if errors.As(r.err, &ert) && len(ert.Frames()) != 0 {
+		return ert.Frames()[0].Pos
+	}
+	return r.pos
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemErr string          `xml:"system-err,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the results of suites to path as JUnit XML.
+// An error outside of tests is reported as an error of the suite.
+func writeJUnit(path string, suites []*testSuite) error {
+	report := junitTestSuites{Suites: make([]junitTestSuite, len(suites))}
+	for i, s := range suites {
+		js := junitTestSuite{
+			Name:  s.path,
+			Tests: len(s.results),
+			Time:  fmt.Sprintf("%.3f", s.time.Seconds()),
+			Cases: make([]junitTestCase, len(s.results)),
+		}
+		if s.err != nil {
+			js.Errors = 1
+			js.SystemErr = s.err.Error()
+		}
+		for j, r := range s.results {
+			jc := junitTestCase{
+				Name:      r.name,
+				Classname: s.path,
+				File:      r.pos.Filename,
+				Line:      r.pos.Line,
+				Time:      fmt.Sprintf("%.3f", r.time.Seconds()),
+			}
+			if r.err != nil {
+				js.Failures++
+				jc.Failure = &junitFailure{
+					Message: fmt.Sprintf("%s: %s", r.failurePos(), errs.Message(r.err)),
+					Text:    r.err.Error(),
+				}
+			}
+			js.Cases[j] = jc
+		}
+		report.Suites[i] = js
+	}
+	data, err := xml.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
@@ -0,0 +1,174 @@
+// Package coa runs Coa code in Go programs.
+//
+// A Runtime keeps the variables defined by the code it evaluates, so Go code can
+// set inputs, register natives, evaluate code and read results:
+//
+//	r := coa.New()
+//	r.Stdout = w
+//	err := r.RegisterNative("@greet", func(args []interface{}) (interface{}, error) {
+//		return "hello, " + args[0].(string), nil
+//	}, coa.Args(coa.String))
+//	err = r.Set("name", "world")
+//	result, err := r.Eval(ctx, `(@greet name)`)
+package coa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// Runtime evaluates Coa code in one environment.
+// A Runtime may be used by one goroutine at a time.
+type Runtime struct {
+	Stdout io.Writer
+	// Stdout is written to by @io_out and @io_outln.
+	Stderr io.Writer
+	// Stderr is written to by @io_err and @io_errln.
+	Stdin io.Reader
+	// Stdin is read from by @io_in.
+	// Input is buffered across calls to Eval, so Stdin must not be changed after
+	// it is first read from.
+	Guard parser.ResourcesGuard
+	// Guard guards the resources used by natives, or allows all if nil.
+	Limits *parser.Limits
+	// Limits limits evaluating, or nothing if nil.
+	Deterministic *parser.Deterministic
+	// Deterministic makes evaluating reproducible if not nil (see
+	// parser.Deterministic).
+	Filename string
+	// Filename is used in the positions of evaluated code.
+
+	env   *parser.Env
+	stdio *parser.Stdio
+	n     int
+	// n is the number of calls to Eval, used for positions.
+}
+
+// New returns a Runtime with only the builtins defined, which uses os.Stdout,
+// os.Stderr and os.Stdin and allows all resources.
+func New() *Runtime {
+	return &Runtime{
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Stdin:    os.Stdin,
+		Filename: "coa",
+		env:      parser.NewEnv(lexer.Position{Filename: "coa"}, true),
+	}
+}
+
+// NativeFunc is a native implemented in Go.
+// args and the result are converted like Set and Get do, except that a nil result
+// is nil.
+// It may be called concurrently.
+type NativeFunc func(args []interface{}) (interface{}, error)
+
+// RegisterNative defines name as a native calling fn.
+// Its arguments are checked against signature before calling fn, unless
+// signature is nil.
+// Names of natives usually start with @ like builtins, which makes them visible
+// in all blocks (e.g. in @test).
+func (r *Runtime) RegisterNative(name string, fn NativeFunc, signature *Signature) error {
+	if name == "" {
+		return errors.New("blank name")
+	}
+	options := make([]parser.Option, 0, 1)
+	if signature != nil {
+		options = append(options, signature)
+	}
+	r.env.Def(name, parser.NewNative(util.InfoPure, func(env parser.IEnv, args []parser.Evaler) (parser.Evaler, error) {
+		goArgs := make([]interface{}, len(args))
+		for i, arg := range args {
+			goArgs[i] = ToGo(arg)
+		}
+		result, err := fn(goArgs)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			// like builtins returning nothing
+			return nil, nil
+		}
+		evaler, err := FromGo(result)
+		if err != nil {
+			return nil, fmt.Errorf("%s: result: %w", name, err)
+		}
+		return evaler, nil
+	}, options...))
+	return nil
+}
+
+// Set defines name as value converted by FromGo.
+func (r *Runtime) Set(name string, value interface{}) error {
+	evaler, err := FromGo(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	r.env.Def(name, evaler)
+	return nil
+}
+
+// Get returns the value of name converted by ToGo, and whether it is defined.
+func (r *Runtime) Get(name string) (interface{}, bool) {
+	evaler, ok := r.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return ToGo(evaler), true
+}
+
+// Eval parses and evaluates src, and returns the value of its last node
+// converted by ToGo (or nil if src is blank).
+// Variables defined by src stay defined for later calls.
+// Evaluating stops with an error wrapping ctx.Err() (see parser.CanceledError)
+// when ctx is done.
+// Panics while evaluating (e.g. in natives) are returned as a
+// *parser.PanicError.
+func (r *Runtime) Eval(ctx context.Context, src string) (_ interface{}, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		// src may be untrusted, so it must not crash the host
+		if v := recover(); v != nil {
+			err = &parser.PanicError{Value: v}
+		}
+	}()
+	r.n++
+	filename := r.Filename
+	if r.n > 1 {
+		filename = fmt.Sprintf("%s#%d", r.Filename, r.n)
+	}
+	root := parser.Nodes{}
+	err = parser.Parser.Parse(filename, bytes.NewBufferString(src), &root)
+	if err != nil {
+		return nil, err
+	}
+	if r.stdio == nil {
+		r.stdio = &parser.Stdio{In: r.Stdin}
+	}
+	r.stdio.Out = r.Stdout
+	r.stdio.Err = r.Stderr
+	r.env.Stdio = r.stdio
+	r.env.ResourcesGuard = r.Guard
+	r.env.Limits = r.Limits
+	r.env.Deterministic = r.Deterministic
+	r.env.Ctx = ctx
+	// nodes are evaluated one by one, as variables defined by src may only be
+	// used by Get or later calls (root.Eval would reject them as unused)
+	var result parser.Evaler
+	for _, n := range root.Select() {
+		result, err = parser.Eval(n, r.env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ToGo(result), nil
+}
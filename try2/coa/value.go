@@ -0,0 +1,145 @@
+package coa
+
+import (
+	"fmt"
+	"reflect"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// Signature is the types of the arguments of a native (see Args).
+type Signature = parser.Signature
+
+// Type is a type of arguments of natives.
+type Type interface{}
+
+// Types of arguments of natives.
+var (
+	Any      Type = parser.TypeAny
+	Number   Type = parser.TypeNumber
+	String   Type = parser.TypeString
+	Bool     Type = parser.TypeBool
+	Rune     Type = parser.TypeRune
+	List     Type = new(parser.List)
+	Map      Type = parser.TypeMap
+	Callable Type = parser.TypeCallable
+)
+
+// Args returns a Signature for natives taking arguments of types.
+func Args(types ...Type) *Signature {
+	ts := make([]interface{}, len(types))
+	for i, t := range types {
+		ts[i] = t
+	}
+	return &Signature{Types: ts}
+}
+
+// Variadic returns a Signature for natives taking any number of arguments of t.
+func Variadic(t Type) *Signature {
+	return &Signature{Types: []interface{}{t}, Variadic: true}
+}
+
+// ToGo converts evaler to a Go value:
+//
+//	number          float64 (complex128 for complex numbers)
+//	string          string
+//	bool            bool
+//	rune            rune
+//	list            []interface{}
+//	map             map[string]interface{}
+//
+// Other values (e.g. blocks) are returned as is.
+func ToGo(evaler parser.Evaler) interface{} {
+	switch evaler := evaler.(type) {
+	case nil:
+		return nil
+	case *parser.Number:
+		return float64(*evaler)
+	case *parser.Float:
+		return float64(*evaler)
+	case *parser.Int:
+		return float64(*evaler)
+	case *parser.Complex:
+		return complex128(*evaler)
+	case *parser.String:
+		return evaler.Content
+	case *parser.Bool:
+		return evaler.Content
+	case *parser.Rune:
+		return rune(*evaler)
+	case *parser.List:
+		content := evaler.Content.Content
+		re := make([]interface{}, len(content))
+		for i, node := range content {
+			re[i] = ToGo(node.Select())
+		}
+		return re
+	case *parser.Map:
+		re := make(map[string]interface{}, len(evaler.Content))
+		for key, value := range evaler.Content {
+			re[key] = ToGo(value)
+		}
+		return re
+	default:
+		return evaler
+	}
+}
+
+// FromGo converts a Go value to a parser.Evaler, the reverse of ToGo.
+// Integers and floats become numbers, and slices and maps with string keys become
+// lists and maps.
+// Note that int32 is rune, so it becomes a rune.
+func FromGo(value interface{}) (parser.Evaler, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("cannot convert nil")
+	case parser.Evaler:
+		return value, nil
+	case string:
+		return parser.NewString(value), nil
+	case bool:
+		return parser.NewBool(value), nil
+	case rune:
+		r := parser.Rune(value)
+		return &r, nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int64:
+		return parser.NewNumber(float64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return parser.NewNumber(float64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return parser.NewNumber(rv.Float()), nil
+	case reflect.Complex64, reflect.Complex128:
+		c := parser.Complex(rv.Complex())
+		return &c, nil
+	case reflect.Slice, reflect.Array:
+		nodes := make([]parser.Node, rv.Len())
+		for i := range nodes {
+			evaler, err := FromGo(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			nodes[i] = parser.Node{Evaler: evaler}
+		}
+		return &parser.List{Content: parser.Nodes{Content: nodes}}, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert %T (keys must be strings)", value)
+		}
+		m := &parser.Map{Content: make(map[string]parser.Evaler, rv.Len())}
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			evaler, err := FromGo(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+			m.Content[key] = evaler
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T", value)
+	}
+}
@@ -3,12 +3,18 @@ package compile
 import (
 	"fmt"
 	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
 )
 
 type Instruction struct {
 	Opcode Opcode
 	A      int
 	B      interface{}
+
+	pos *lexer.Position
+	// pos is the position of the node the instruction was compiled from, shared
+	// by the instructions of the node (see Instructions.SourceMap).
 }
 
 func (i *Instruction) String() string {
@@ -111,6 +117,17 @@ var opcodeInfo = [...]*OpcodeInfo{
 	OpLitNumber: {"LitNumber", "Ln"},
 	OpLitString: {"LitString", "Ls"},
 	OpLitRune:   {"LitRune", "Lr"},
+
+	OpJump:       {"Jump", "J"},
+	OpJumpUnless: {"JumpUnless", "Ju"},
+	OpLoopStart:  {"LoopStart", "Lo"},
+	OpLoopEnd:    {"LoopEnd", "Lx"},
+	OpBodyStart:  {"BodyStart", "Ys"},
+	OpBodyEnd:    {"BodyEnd", "Ye"},
+	OpBreak:      {"Break", "Br"},
+	OpContinue:   {"Continue", "Co"},
+	OpReturn:     {"Return", "R"},
+	OpListAppend: {"ListAppend", "La"},
 }
 
 func (o Opcode) info() *OpcodeInfo {
@@ -165,13 +182,15 @@ const (
 	OpCall
 	// OpCall calls @ with A arguments
 	// i.e. @(@1, @2, @3, ...)
-	//
+	// B is the name of the callee if it is a variable (for profiling), or nil.
+	// The position of the call is set by an OpPos right before.
 
 	OpLit // OpLit pushes a literal value from B. The type is specified using A.
 
 	OpMakeList
 	// OpMakeList makes a list with A elements
 	// i.e. [@1, @2, @3, ...]
+	// B is the opening bracket of the literal, so "[m" makes a map.
 
 	OpMakeString
 	// OpMakeString makes a string with A frames.
@@ -180,23 +199,45 @@ const (
 	OpBlockStart
 	OpBlockEnd
 
-	OpBundleStart
-	OpStrandStart
-	OpStrandTodo
-	OpStrandReverseDeps
-	OpStrandInvoke
-	OpStrandEnd // noop
-	OpBundleEnd // noop
+	OpBundleStart       // runs the strands until the OpBundleEnd A instructions later (see Scope.compileBundle)
+	OpStrandStart       // starts a strand ending A instructions later
+	OpStrandTodo        // the strand runs node A
+	OpStrandReverseDeps // the strand signals the A strands in the following OpStrandInvokes when done
+	OpStrandInvoke      // signal strand A
+	OpStrandEnd         // noop
+	OpBundleEnd         // A is the number of nodes in the bundle
 
 	OpPos // sets the position until the next OpPos with B
 
 	OpLitNumber
 	OpLitString
 	OpLitRune
+
+	OpJump // jumps A instructions forward (backward if negative), relative to this instruction
+	OpJumpUnless
+	// OpJumpUnless pops @ and jumps like OpJump if it is false.
+
+	OpLoopStart
+	// OpLoopStart starts a loop (see OpBreak and OpContinue).
+	// A is the (relative) OpLoopEnd of the loop, and B is where OpContinue jumps to.
+	OpLoopEnd
+
+	OpBodyStart
+	// OpBodyStart starts an inlined block body (see OpReturn).
+	// A is the (relative) OpBodyEnd of the body.
+	OpBodyEnd
+
+	OpBreak    // jumps to the end of the innermost loop, or fails with parser.ErrBreak
+	OpContinue // jumps to the continue point of the innermost loop, or fails with parser.ErrContinue
+	OpReturn
+	// OpReturn pops @ and returns it from the A-th innermost body or block
+	// (i.e. (@return_len @ A)).
+
+	OpListAppend // appends @ (popped) to the list at @1
 )
 
-func op1(code Opcode) Instruction { return Instruction{code, 0, nil} }
+func op1(code Opcode) Instruction { return Instruction{Opcode: code} }
 
-func op(code Opcode, a int) Instruction { return Instruction{code, a, nil} }
+func op(code Opcode, a int) Instruction { return Instruction{Opcode: code, A: a} }
 
-func op3(code Opcode, a int, b interface{}) Instruction { return Instruction{code, a, b} }
+func op3(code Opcode, a int, b interface{}) Instruction { return Instruction{Opcode: code, A: a, B: b} }
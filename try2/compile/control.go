@@ -0,0 +1,209 @@
+package compile
+
+import (
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// compileControl compiles n into jumps if it is a call to a control flow builtin
+// (@if, @while, @for, @break, @continue, @return, @return_len).
+// ok is false if n is not one (or its form cannot be lowered), in which case it
+// must be compiled as a normal call.
+func (s *Scope) compileControl(n *parser.Call) (insts []Instruction, ok bool, err error) {
+	if len(n.Content.Content) == 0 || n.Content.Content[0].ID == nil {
+		return nil, false, nil
+	}
+	name := n.Content.Content[0].ID.Content
+	args := n.Content.Content[1:]
+	switch {
+	case name == "@if" && len(args) > 0:
+		insts, err = s.compileIf(args)
+	case name == "@while" && len(args) == 2:
+		insts, err = s.compileLoop(nil, &args[0], nil, args[1])
+	case name == "@for" && len(args) == 4:
+		insts, err = s.compileLoop(&args[0], &args[1], &args[2], args[3])
+	case name == "@break" && len(args) == 0:
+		insts = []Instruction{op1(OpBreak)}
+	case name == "@continue" && len(args) == 0:
+		insts = []Instruction{op1(OpContinue)}
+	case name == "@return" && len(args) == 1:
+		insts, err = s.compileReturn(args[0], 1)
+	case name == "@return_len" && len(args) == 2 && args[1].Number != nil:
+		insts, err = s.compileReturn(args[0], int(*args[1].Number))
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, NewError(n.Pos, err)
+	}
+	insts = append([]Instruction{op3(OpPos, 0, n.Pos.String())}, insts...)
+	return s.wrap(insts, name), true, nil
+}
+
+func (s *Scope) compileNodeInsts(n parser.Node) ([]Instruction, error) {
+	compiled, err := s.compileNode(n)
+	if err != nil {
+		return nil, NewError(n.Pos, err)
+	}
+	return compiled.insts(), nil
+}
+
+// compileIf compiles (@if cond value cond value ... else).
+//     cond
+//     OpJumpUnless → next
+//     value
+//     OpJump → end
+//   next:
+//     ...
+//     else (or 0)
+//   end:
+func (s *Scope) compileIf(args []parser.Node) ([]Instruction, error) {
+	insts := make([]Instruction, 0)
+	ends := make([]int, 0, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		cond, err := s.compileNodeInsts(args[i])
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, cond...)
+		next := len(insts)
+		insts = append(insts, op(OpJumpUnless, 0)) // placeholder
+		value, err := s.compileNodeInsts(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, value...)
+		ends = append(ends, len(insts))
+		insts = append(insts, op(OpJump, 0)) // placeholder
+		insts[next].A = len(insts) - next
+	}
+	if len(args)%2 == 1 {
+		value, err := s.compileNodeInsts(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, value...)
+	} else {
+		insts = append(insts, (&litNumberNode{Number: 0}).insts()...)
+	}
+	for _, end := range ends {
+		insts[end].A = len(insts) - end
+	}
+	return insts, nil
+}
+
+// compileLoop compiles (@for init cond iter body), or (@while cond body) if init
+// and iter are nil. The results of body are collected into a list like the natives do.
+//     OpMakeList 0
+//     init, OpPop 1
+//     OpLoopStart → end, cont
+//   cond:
+//     cond
+//     OpJumpUnless → end
+//     body
+//     OpListAppend
+//     OpJump → iter
+//   cont:
+//     OpLit (nil), OpListAppend
+//   iter:
+//     iter, OpPop 1
+//     OpJump → cond
+//   end:
+//     OpLoopEnd
+// @while has no init, cont and iter, and continues at cond.
+func (s *Scope) compileLoop(init, cond, iter *parser.Node, body parser.Node) ([]Instruction, error) {
+	insts := []Instruction{op(OpMakeList, 0)}
+	if init != nil {
+		initInsts, err := s.compileNodeInsts(*init)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, initInsts...)
+		insts = append(insts, op(OpPop, 1))
+	}
+	start := len(insts)
+	insts = append(insts, op(OpLoopStart, 0)) // placeholder
+	condStart := len(insts)
+	condInsts, err := s.compileNodeInsts(*cond)
+	if err != nil {
+		return nil, err
+	}
+	insts = append(insts, condInsts...)
+	exit := len(insts)
+	insts = append(insts, op(OpJumpUnless, 0)) // placeholder
+	bodyInsts, err := s.compileBody(body)
+	if err != nil {
+		return nil, err
+	}
+	insts = append(insts, bodyInsts...)
+	insts = append(insts, op1(OpListAppend))
+	cont := condStart
+	if iter != nil {
+		// (@for) appends a nil result when continuing
+		insts = append(insts, op(OpJump, 3))
+		cont = len(insts)
+		insts = append(insts, op1(OpLit), op1(OpListAppend))
+		iterInsts, err := s.compileNodeInsts(*iter)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, iterInsts...)
+		insts = append(insts, op(OpPop, 1))
+	}
+	insts = append(insts, op(OpJump, condStart-len(insts)))
+	insts[exit].A = len(insts) - exit
+	insts[start] = op3(OpLoopStart, len(insts)-start, cont-start)
+	insts = append(insts, op1(OpLoopEnd))
+	return insts, nil
+}
+
+// compileBody compiles calling body without arguments.
+// A literal block is inlined into s between OpBodyStart and OpBodyEnd, so that
+// (@return) inside it only ends the body. Its variables are stored in s, but
+// shadow the variables of s and are hidden after the body, like in the scope of
+// a called block.
+func (s *Scope) compileBody(body parser.Node) ([]Instruction, error) {
+	if body.Block == nil {
+		insts, err := s.compileNodeInsts(body)
+		if err != nil {
+			return nil, err
+		}
+		return append(insts, op(OpCall, 1)), nil
+	}
+	start := len(s.keys)
+	s.bodies = append(s.bodies, start)
+	defer func() {
+		s.bodies = s.bodies[:len(s.bodies)-1]
+		for i := start; i < len(s.keys); i++ {
+			// the slot stays declared, but cannot be looked up anymore
+			s.keys[i] = ""
+		}
+	}()
+	insts := []Instruction{op(OpBodyStart, 0)} // placeholder
+	for i, n := range body.Block.Content.Content {
+		if i != 0 {
+			insts = append(insts, op(OpPop, 1))
+		}
+		nodeInsts, err := s.compileNodeInsts(n)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, nodeInsts...)
+	}
+	if len(body.Block.Content.Content) == 0 {
+		insts = append(insts, op1(OpLit))
+	}
+	insts[0].A = len(insts)
+	return append(insts, op1(OpBodyEnd)), nil
+}
+
+// compileReturn compiles (@return_len value n).
+func (s *Scope) compileReturn(value parser.Node, n int) ([]Instruction, error) {
+	insts, err := s.compileNodeInsts(value)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		n = 1 // same as parser.ReturnVals
+	}
+	return append(insts, op(OpReturn, n)), nil
+}
@@ -27,3 +27,5 @@ func NewError(pos lexer.Position, err error) *Error { return &Error{pos, err} }
 func (c *Error) Error() string {
 	return fmt.Sprintf("%s: %s", c.Pos, c.Err)
 }
+
+func (c *Error) Position() lexer.Position { return c.Pos }
@@ -9,11 +9,16 @@ import (
 	"gitlab.com/coalang/go-coa/try2/parser"
 )
 
-const compileBundle = false
-
 type CompileEnv struct {
 	Pos       lexer.Position
 	constants []parser.Node
+
+	// Parallel makes blocks run their nodes in parallel strands (see compileBundle),
+	// like parser.Env does when parallel evaluation is allowed.
+	Parallel bool
+
+	base parser.IEnv
+	// base is used to find out if nodes use resources (and so must not run in parallel).
 }
 
 func NewEnv(pos lexer.Position) *CompileEnv {
@@ -26,6 +31,10 @@ type Scope struct {
 	keys           []string
 	parent         *Scope
 	levelsFromRoot int
+
+	bodies []int
+	// bodies are the indices of keys where the inlined bodies being compiled start
+	// (see compileBody).
 }
 
 func (c *CompileEnv) newScope(pos lexer.Position) *Scope {
@@ -71,19 +80,72 @@ func (s *Scope) CompileNodes(n parser.Nodes) ([]Instruction, error) {
 }
 
 func (s *Scope) compileNodes(n parser.Nodes) (compiledNode, error) {
-	nodes := make([]compiledNode, 1, len(n.Content))
-	for _, n := range n.Content {
-		node, err := s.compileNode(n)
+	insts, err := s.compileSeq(n.Pos, n.Content, true)
+	if err != nil {
+		return nil, err
+	}
+	insts = append([]Instruction{op(OpVarDeclare, len(s.keys))}, insts...)
+	setPos(insts, n.Pos)
+	return &instsNode{raw: insts}, nil
+}
+
+// compileSeq compiles nodes to be run one after another (or in parallel strands
+// if allowed by parallel and s.c.Parallel), each pushing its value.
+func (s *Scope) compileSeq(pos lexer.Position, ns []parser.Node, parallel bool) ([]Instruction, error) {
+	outer := s.allKeys()
+	evalers := make([]parser.Evaler, len(ns))
+	nodes := make([]compiledNode, len(ns))
+	for i, n := range ns {
+		evalers[i] = n.Select()
+		var err error
+		nodes[i], err = s.compileNode(n)
 		if err != nil {
 			return nil, err
 		}
-		nodes = append(nodes, node)
 	}
-	nodes[0] = &instsNode{raw: []Instruction{op(OpVarDeclare, len(s.keys))}}
-	return &compiledNodes{Nodes: nodes}, nil
+	if parallel && s.c.Parallel && len(nodes) > 1 && s.c.isPure(evalers) {
+		ss, err := parser.CompileEvalers(outer, evalers)
+		if err != nil {
+			return nil, err
+		}
+		if len(ss) > 1 {
+			return s.compileBundle(pos, ss, nodes), nil
+		}
+	}
+	insts := make([]Instruction, 0, len(nodes))
+	for _, node := range nodes {
+		insts = append(insts, node.insts()...)
+	}
+	return insts, nil
 }
 
+// isPure reports whether evalers use no resources, like parser.Env requires for
+// evaluating in parallel.
+func (c *CompileEnv) isPure(evalers []parser.Evaler) bool {
+	if c.base == nil {
+		c.base = parser.NewEnv(c.Pos, false)
+	}
+	for _, evaler := range evalers {
+		if len(evaler.Info(c.base).Resources) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compileNode compiles n, and sets the position of its instructions (see
+// Instructions.SourceMap).
 func (s *Scope) compileNode(n parser.Node) (compiledNode, error) {
+	compiled, err := s.compileNode2(n)
+	if err != nil {
+		return nil, err
+	}
+	insts := compiled.insts()
+	setPos(insts, n.Pos)
+	return &instsNode{raw: insts}, nil
+}
+
+func (s *Scope) compileNode2(n parser.Node) (compiledNode, error) {
 	switch {
 	case n.Number != nil:
 		return &litNumberNode{Number: float64(*n.Number)}, nil
@@ -152,10 +214,8 @@ type Symbol = int
 func (s *Scope) getSymbol(key string) (sym Symbol, level int, ok bool) {
 	level = 0
 	for s != nil {
-		for i, k := range s.keys {
-			if k == key {
-				return Symbol(i), level, true
-			}
+		if i := s.keyIndex(key); i != -1 {
+			return Symbol(i), level, true
 		}
 		s = s.parent
 		level++
@@ -163,7 +223,32 @@ func (s *Scope) getSymbol(key string) (sym Symbol, level int, ok bool) {
 	return 0, 0, false
 }
 
+// keyIndex returns the index of key in s.keys, or -1 if not found.
+// The keys of the inlined bodies being compiled are looked up first, innermost
+// first, so that they shadow the other keys like in an inner scope.
+func (s *Scope) keyIndex(key string) int {
+	end := len(s.keys)
+	for i := len(s.bodies); i >= 0; i-- {
+		start := 0
+		if i > 0 {
+			start = s.bodies[i-1]
+		}
+		for j := start; j < end; j++ {
+			if s.keys[j] == key {
+				return j
+			}
+		}
+		end = start
+	}
+	return -1
+}
+
 func (s *Scope) compileCall(n *parser.Call) (*instsNode, error) {
+	if insts, ok, err := s.compileControl(n); err != nil {
+		return nil, err
+	} else if ok {
+		return &instsNode{raw: insts}, nil
+	}
 	{
 		a := n.Content.Content[0]
 		if a.ID == nil {
@@ -179,7 +264,9 @@ func (s *Scope) compileCall(n *parser.Call) (*instsNode, error) {
 			return nil, NewError(n.Pos, errors.New("def expects a name"))
 		}
 		name := (*b.ID).Content
-		s.keys = append(s.keys, name)
+		if define || s.keyIndex(name) == -1 {
+			s.keys = append(s.keys, name)
+		}
 		sym, level, ok := s.getSymbol(name)
 		if !ok {
 			panic("symbol not found although it should have been added right before")
@@ -199,6 +286,8 @@ func (s *Scope) compileCall(n *parser.Call) (*instsNode, error) {
 		} else if redefine {
 			insts = append(insts, op3(OpVarReassign, sym, name))
 		}
+		// @def and @mod return the value like the natives do
+		insts = append(insts, op3(OpVarLoad, sym, 0))
 		return &instsNode{raw: insts}, nil
 	}
 Normal:
@@ -213,34 +302,24 @@ Normal:
 		}
 		insts = append(insts, compiled.insts()...)
 	}
-	insts = append(insts, op(OpCall, len(n.Content.Content)))
+	// the position is set again as the nodes may have set their own
+	insts = append(insts, op3(OpPos, 0, n.Pos.String()))
+	var callee interface{}
+	if a := n.Content.Content[0]; a.ID != nil {
+		callee = a.ID.Content
+	}
+	insts = append(insts, op3(OpCall, len(n.Content.Content), callee))
 	return &instsNode{raw: s.wrap(insts, "call "+n.String())}, nil
 }
 
 func (s *Scope) compileBlock(n *parser.Block) (compiledNode, error) {
 	ns := s.inherit(n.Pos)
-	evalers := make([]parser.Evaler, len(n.Content.Content))
-	nodes := make([]compiledNode, len(n.Content.Content))
-	for i, n := range n.Content.Content {
-		evalers[i] = n.Select()
-		var err error
-		nodes[i], err = ns.compileNode(n)
-		if err != nil {
-			return nil, err
-		}
-	}
-	ss, err := parser.CompileEvalers(ns.allKeys(), evalers)
+	nodesInsts, err := ns.compileSeq(n.Pos, n.Content.Content, n.RunParallel())
 	if err != nil {
 		return nil, err
 	}
-	insts := make([]Instruction, 3)
-	if compileBundle {
-		insts = append(insts, s.compileBundle(n.Pos, ss, nodes)...)
-	} else {
-		for _, node := range nodes {
-			insts = append(insts, node.insts()...)
-		}
-	}
+	insts := make([]Instruction, 3, len(nodesInsts)+4)
+	insts = append(insts, nodesInsts...)
 	insts = append(insts, op1(OpBlockEnd))
 	insts[0] = op3(OpPos, 0, n.Pos.String())
 	insts[1] = op(OpBlockStart, len(insts)-1)
@@ -253,79 +332,52 @@ func (s *Scope) compileBlock(n *parser.Block) (compiledNode, error) {
 	return &instsNode{raw: s.wrap(insts, "block")}, nil
 }
 
-type bundleNode struct {
-	ss    []*parser.Strand
-	nodes []compiledNode
-}
-
-// insts generates instructions for running this bundle in parallel.
-//     OpBundleStart 21
-//     OpStrandStart 7
-//     OpStrandTodo 2
-//     {(@def a 1)}
-//     {(@def b a)}
-//     OpStrandReverseDeps 2
+// compileBundle generates instructions for running nodes in parallel as strands ss.
+// Each strand lists the nodes it runs (in order) and the strands to signal when
+// it is done, followed by the instructions of its nodes:
+//     OpBundleStart 17
+//     OpStrandStart 8
+//     OpStrandTodo 0
+//     OpStrandTodo 1
+//     OpStrandReverseDeps 1
 //     OpStrandInvoke 1
-//     OpStrandInvoke 2
+//     (@def a 1)
+//     (@def b a)
 //     OpStrandEnd
 //     OpStrandStart 5
-//     OpStrandTodo 1
-//     {(@def c b)}
-//     OpStrandReverseDeps 2
-//     OpStrandInvoke 2
-//     OpStrandEnd
-//     OpStrandStart 4
-//     OpStrandTodo 1
-//     {(@def d [a b c])}
+//     OpStrandTodo 2
 //     OpStrandReverseDeps 0
+//     (@def c b)
 //     OpStrandEnd
-//     OpBundleEnd
-//     OpBundleEnd
+//     OpBundleEnd 3
+// Once all strands are done, the values of the nodes are pushed in order.
 func (s *Scope) compileBundle(pos lexer.Position, ss []*parser.Strand, nodes []compiledNode) []Instruction {
-	insts := make([]Instruction, 3)
-	insts[0] = op3(OpWrap, s.levelsFromRoot, "bundle")
-	insts[1] = op3(OpPos, 0, pos.String())
-	insts[2] = op(OpBundleStart, -1) // placeholder
+	insts := make([]Instruction, 2)
+	insts[0] = op3(OpPos, 0, pos.String())
 	for _, ss := range ss {
-		insts2 := make([]Instruction, 1)
-		insts2[0] = op(OpStrandStart, -1)
-
-		// Strand Todo
-		todoInsts := make([]Instruction, 1)
-		todoInsts[0] = op(OpStrandTodo, -1)
+		strand := make([]Instruction, 1, len(ss.Todo)+len(ss.ReverseDeps)+3)
 		for _, nodeI := range ss.Todo {
-			node := nodes[nodeI]
-			nodeInsts := node.insts()
-			todoInsts = append(insts2, nodeInsts...)
+			strand = append(strand, op(OpStrandTodo, nodeI))
 		}
-		todoInsts = append(todoInsts, op(OpStrandEnd, 0))
-		todoInsts[0] = op(OpStrandTodo, len(todoInsts)-1)
-		todoInsts = s.wrap(todoInsts, "strand todo")
-		insts2 = append(insts2, todoInsts...)
-
-		// Strand Reverse Deps
-		rdInsts := make([]Instruction, 1)
-		rdInsts[0] = op(OpStrandReverseDeps, -1)
+		strand = append(strand, op(OpStrandReverseDeps, len(ss.ReverseDeps)))
 		for _, depI := range ss.ReverseDeps {
-			rdInsts = append(rdInsts, op(OpStrandInvoke, depI))
+			strand = append(strand, op(OpStrandInvoke, depI))
+		}
+		for _, nodeI := range ss.Todo {
+			strand = append(strand, nodes[nodeI].insts()...)
 		}
-		rdInsts[0] = op(OpStrandReverseDeps, len(rdInsts)-1)
-		rdInsts = s.wrap(rdInsts, "strand reverse deps")
-		insts2 = append(insts2, rdInsts...)
-
-		insts2[0] = op(OpStrandStart, len(insts2)-1)
-		insts2 = append(insts2, op1(OpStrandEnd))
-		insts2 = s.wrap(insts2, "strand")
-		insts = append(insts, insts2...)
+		strand[0] = op(OpStrandStart, len(strand))
+		strand = append(strand, op1(OpStrandEnd))
+		insts = append(insts, strand...)
 	}
-	insts[2] = op(OpBundleStart, len(insts)-1)
-	insts = append(insts, op1(OpBundleEnd))
+	insts[1] = op(OpBundleStart, len(insts)-1)
+	insts = append(insts, op(OpBundleEnd, len(nodes)))
 	return s.wrap(insts, "bundle")
 }
 
 func (s *Scope) compileList(l *parser.List) (compiledNode, error) {
 	insts := make([]Instruction, 0, len(l.Content.Content)+2) // assume each node makes 1+ insts, same some growing operations
-	insts = append(insts, op3(OpPos, 0, l.Pos))
+	insts = append(insts, op3(OpPos, 0, l.Pos.String()))
 	for _, n := range l.Content.Content {
 		compiled, err := s.compileNode(n)
 		if err != nil {
@@ -334,7 +386,7 @@ func (s *Scope) compileList(l *parser.List) (compiledNode, error) {
 		insts = append(insts, compiled.insts()...)
 		// @ = n
 	}
-	insts = append(insts, op(OpMakeList, len(l.Content.Content)))
+	insts = append(insts, op3(OpMakeList, len(l.Content.Content), l.O))
 	return &instsNode{raw: s.wrap(insts, "list")}, nil
 }
 
@@ -0,0 +1,116 @@
+package compile
+
+import (
+	"sort"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// SourceMap maps offsets of Instructions to the positions of the nodes they were
+// compiled from. It is a side table: each distinct position is stored once, and
+// each run of consecutive instructions from the same node is one entry.
+type SourceMap struct {
+	Positions []lexer.Position
+	Runs      []SourceRun
+	// Runs are sorted by Start. A run lasts until the Start of the next one.
+}
+
+// SourceRun is a run of instructions compiled from the node at Positions[Pos],
+// starting at the offset Start. Pos is -1 for instructions without a position.
+type SourceRun struct {
+	Start int
+	Pos   int
+}
+
+// Pos returns the position of the instruction at offset.
+func (m *SourceMap) Pos(offset int) (lexer.Position, bool) {
+	i := sort.Search(len(m.Runs), func(i int) bool { return m.Runs[i].Start > offset }) - 1
+	if i < 0 || m.Runs[i].Pos < 0 || m.Runs[i].Pos >= len(m.Positions) {
+		return lexer.Position{}, false
+	}
+	return m.Positions[m.Runs[i].Pos], true
+}
+
+// Pos returns the position of the node the instruction at offset was compiled
+// from. ok is false if unknown, e.g. for instructions not made by the compiler
+// or decoded without a SourceMap.
+func (is Instructions) Pos(offset int) (pos lexer.Position, ok bool) {
+	if offset < 0 || offset >= len(is) {
+		return lexer.Position{}, false
+	}
+	return is[offset].Pos()
+}
+
+// Pos returns the position of the node i was compiled from, like
+// Instructions.Pos.
+func (i *Instruction) Pos() (pos lexer.Position, ok bool) {
+	if i.pos == nil {
+		return lexer.Position{}, false
+	}
+	return *i.pos, true
+}
+
+// SourceMap returns the positions of is as a SourceMap.
+func (is Instructions) SourceMap() *SourceMap {
+	m := &SourceMap{Positions: make([]lexer.Position, 0), Runs: make([]SourceRun, 0)}
+	indices := map[lexer.Position]int{}
+	var prev *lexer.Position
+	for offset, inst := range is {
+		if offset != 0 && inst.pos == prev {
+			continue
+		}
+		prev = inst.pos
+		run := SourceRun{Start: offset, Pos: -1}
+		if inst.pos != nil {
+			index, ok := indices[*inst.pos]
+			if !ok {
+				index = len(m.Positions)
+				indices[*inst.pos] = index
+				m.Positions = append(m.Positions, *inst.pos)
+			}
+			run.Pos = index
+		}
+		if n := len(m.Runs); n != 0 && m.Runs[n-1].Pos == run.Pos {
+			continue
+		}
+		m.Runs = append(m.Runs, run)
+	}
+	return m
+}
+
+// SetSourceMap sets the positions of is from m, e.g. after decoding is.
+func (is Instructions) SetSourceMap(m *SourceMap) {
+	positions := make([]*lexer.Position, len(m.Positions))
+	for i := range m.Positions {
+		positions[i] = &m.Positions[i]
+	}
+	for i, run := range m.Runs {
+		end := len(is)
+		if i+1 < len(m.Runs) && m.Runs[i+1].Start < end {
+			end = m.Runs[i+1].Start
+		}
+		var pos *lexer.Position
+		if run.Pos >= 0 && run.Pos < len(positions) {
+			pos = positions[run.Pos]
+		}
+		for offset := run.Start; offset < end; offset++ {
+			if offset >= 0 {
+				is[offset].pos = pos
+			}
+		}
+	}
+}
+
+// setPos sets the position of the instructions of a node at pos which have none
+// yet, i.e. those not compiled from an inner node.
+// Nothing is set if pos is unknown, so that the outer node's position is used.
+func setPos(insts []Instruction, pos lexer.Position) {
+	if pos == (lexer.Position{}) {
+		return
+	}
+	for i := range insts {
+		if insts[i].pos == nil {
+			insts[i].pos = &pos
+		}
+	}
+}
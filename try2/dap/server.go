@@ -0,0 +1,422 @@
+// Package dap implements a debug adapter for Coa over stdio, so that editors
+// speaking the Debug Adapter Protocol can debug Coa files.
+//
+// The program is run by the engine chosen by the launch configuration (see
+// LaunchArguments) as one thread. While paused, the call stack, the variables of
+// each frame and watch expressions can be inspected (see parser.Paused).
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/compile"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+	"gitlab.com/coalang/go-coa/try2/vm"
+)
+
+// threadID is the ID of the only thread, as strands evaluated in parallel pause
+// one at a time.
+const threadID = 1
+
+// Server is a debug adapter.
+type Server struct {
+	debugger *parser.Debugger
+
+	w         io.Writer
+	writeLock sync.Mutex
+	seq       int
+	// seq is the seq of the last message written, locked by writeLock.
+
+	launch     *LaunchArguments
+	configured bool
+	cancel     context.CancelFunc
+	done       chan struct{}
+	// done is closed when the program ends.
+
+	lock   sync.Mutex
+	paused parser.Paused
+	// paused is the program while paused, or nil.
+	frames []parser.Frame
+	vars   [][]parser.Var
+	// vars are the variables of scopes by variablesReference-1, until the next
+	// stop.
+	stops  int
+	resume chan parser.Action
+	// resume receives how to continue while paused.
+}
+
+func NewServer() *Server {
+	s := &Server{resume: make(chan parser.Action)}
+	s.debugger = parser.NewDebugger(s.onStop)
+	return s
+}
+
+// Serve handles the requests read from r and writes responses and events to w,
+// until the disconnect request or the end of r.
+// The program is stopped before Serve returns.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	defer s.stop()
+	br := bufio.NewReader(r)
+	for {
+		m, err := ReadMessage(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if m.Type != "request" {
+			continue
+		}
+		body, err := s.handle(m)
+		resp := &response{Type: "response", RequestSeq: m.Seq, Success: err == nil, Command: m.Command, Body: body}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+		switch m.Command {
+		case "initialize":
+			err = s.event("initialized", nil)
+		case "launch", "configurationDone":
+			if s.launch != nil && s.configured && s.done == nil {
+				s.start()
+			}
+		case "disconnect":
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// write writes m with the next seq.
+func (s *Server) write(m interface{}) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.seq++
+	switch m := m.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+	return WriteMessage(s.w, m)
+}
+
+func (s *Server) event(name string, body interface{}) error {
+	return s.write(&event{Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) handle(m *Message) (interface{}, error) {
+	switch m.Command {
+	case "initialize":
+		return Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		if s.launch != nil {
+			return nil, errors.New("already launched")
+		}
+		args := new(LaunchArguments)
+		if err := unmarshalArguments(m, args); err != nil {
+			return nil, err
+		}
+		if args.Program == "" {
+			return nil, errors.New("program not given")
+		}
+		switch args.Engine {
+		case "", "vm", "interp":
+		default:
+			return nil, fmt.Errorf("unknown engine %s: want vm or interp", args.Engine)
+		}
+		program, err := filepath.Abs(args.Program)
+		if err != nil {
+			return nil, err
+		}
+		args.Program = program
+		s.launch = args
+		return nil, nil
+	case "configurationDone":
+		s.configured = true
+		return nil, nil
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := unmarshalArguments(m, &args); err != nil {
+			return nil, err
+		}
+		path, err := filepath.Abs(args.Source.Path)
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]int, len(args.Breakpoints))
+		body := SetBreakpointsResponseBody{Breakpoints: make([]Breakpoint, len(args.Breakpoints))}
+		for i, b := range args.Breakpoints {
+			lines[i] = b.Line
+			body.Breakpoints[i] = Breakpoint{Verified: true, Line: b.Line}
+		}
+		s.debugger.SetBreakpoints(path, lines)
+		return body, nil
+	case "threads":
+		return ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		var args ScopesArguments
+		if err := unmarshalArguments(m, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args.FrameID)
+	case "variables":
+		var args VariablesArguments
+		if err := unmarshalArguments(m, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args.VariablesReference)
+	case "evaluate":
+		var args EvaluateArguments
+		if err := unmarshalArguments(m, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue":
+		return ContinueResponseBody{AllThreadsContinued: true}, s.continue_(parser.ActionContinue)
+	case "next":
+		return nil, s.continue_(parser.ActionStepOver)
+	case "stepIn":
+		return nil, s.continue_(parser.ActionStepInto)
+	case "stepOut":
+		return nil, s.continue_(parser.ActionStepOut)
+	case "pause":
+		s.debugger.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.stop()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported command %s", m.Command)
+	}
+}
+
+func unmarshalArguments(m *Message, args interface{}) error {
+	err := json.Unmarshal(m.Arguments, args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// start runs the program in a new goroutine, and sends the exited and terminated
+// events when it ends.
+func (s *Server) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	if s.launch.StopOnEntry {
+		s.debugger.Pause()
+	}
+	if len(s.launch.Args) != 0 {
+		parser.OsArgs = append([]string{s.launch.Program}, s.launch.Args...)
+	}
+	go func() {
+		defer close(s.done)
+		err := s.run(ctx)
+		code := 0
+		if err != nil && !errors.Is(err, parser.ErrQuit) && ctx.Err() == nil {
+			code = 1
+			_ = s.event("output", OutputEventBody{Category: "stderr", Output: err.Error() + "\n"})
+		}
+		_ = s.event("exited", ExitedEventBody{ExitCode: code})
+		_ = s.event("terminated", nil)
+	}()
+}
+
+// run runs the program until it ends or ctx is done.
+func (s *Server) run(ctx context.Context) error {
+	path := s.launch.Program
+	stdio := &parser.Stdio{
+		In:  strings.NewReader(""),
+		Out: &outputWriter{s: s, category: "stdout"},
+		Err: &outputWriter{s: s, category: "stderr"},
+	}
+	if s.launch.Engine == "interp" {
+		env := parser.NewEnv(lexer.Position{Filename: path}, s.launch.Parallel)
+		env.Stdio = stdio
+		env.Ctx = ctx
+		env.Debugger = s.debugger
+		_, err := env.LoadPath(path)
+		return err
+	}
+	root, err := parser.ParsePath(path)
+	if err != nil {
+		return err
+	}
+	ce := compile.NewEnv(lexer.Position{Filename: path})
+	ce.Parallel = s.launch.Parallel
+	insts, err := ce.NewScope().CompileNodes(*root)
+	if err != nil {
+		return err
+	}
+	v := vm.NewVM()
+	v.Stdio = stdio
+	v.Debugger = s.debugger
+	return v.ExecuteContext(ctx, vm.NewProgram(insts))
+}
+
+// stop stops the program, if running, and waits for it to end.
+func (s *Server) stop() {
+	if s.done == nil {
+		return
+	}
+	s.cancel()
+	for {
+		select {
+		case <-s.done:
+			return
+		case s.resume <- parser.ActionQuit:
+		}
+	}
+}
+
+// onStop sends the stopped event, and waits for a request to continue.
+func (s *Server) onStop(p parser.Paused, stop parser.Stop) parser.Action {
+	s.lock.Lock()
+	s.paused = p
+	s.frames = p.Frames()
+	s.vars = nil
+	s.stops++
+	first := s.stops == 1
+	s.lock.Unlock()
+
+	reason := string(stop.Reason)
+	if first && stop.Reason == parser.StopPause && s.launch.StopOnEntry {
+		reason = "entry"
+	}
+	err := s.event("stopped", StoppedEventBody{Reason: reason, ThreadID: threadID, AllThreadsStopped: true})
+	if err != nil {
+		return parser.ActionQuit
+	}
+	action := <-s.resume
+
+	s.lock.Lock()
+	s.paused = nil
+	s.frames = nil
+	s.vars = nil
+	s.lock.Unlock()
+	return action
+}
+
+// continue_ continues the paused program with action.
+func (s *Server) continue_(action parser.Action) error {
+	s.lock.Lock()
+	paused := s.paused != nil
+	s.lock.Unlock()
+	if !paused {
+		return errors.New("not paused")
+	}
+	s.resume <- action
+	return nil
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.paused == nil {
+		return nil, errors.New("not paused")
+	}
+	body := StackTraceResponseBody{StackFrames: make([]StackFrame, len(s.frames)), TotalFrames: len(s.frames)}
+	for i, f := range s.frames {
+		body.StackFrames[i] = StackFrame{
+			ID:     i + 1,
+			Name:   f.Name,
+			Source: &Source{Name: filepath.Base(f.Pos.Filename), Path: f.Pos.Filename},
+			Line:   f.Pos.Line,
+			Column: f.Pos.Column,
+		}
+	}
+	return body, nil
+}
+
+func (s *Server) scopes(frameID int) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.paused == nil {
+		return nil, errors.New("not paused")
+	}
+	if frameID < 1 || frameID > len(s.frames) {
+		return nil, fmt.Errorf("unknown frame %d", frameID)
+	}
+	body := ScopesResponseBody{Scopes: make([]Scope, 0)}
+	for _, scope := range s.frames[frameID-1].Scopes {
+		s.vars = append(s.vars, scope.Vars)
+		body.Scopes = append(body.Scopes, Scope{Name: scope.Name, VariablesReference: len(s.vars)})
+	}
+	return body, nil
+}
+
+func (s *Server) variables(ref int) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ref < 1 || ref > len(s.vars) {
+		return nil, fmt.Errorf("unknown variablesReference %d", ref)
+	}
+	body := VariablesResponseBody{Variables: make([]Variable, len(s.vars[ref-1]))}
+	for i, v := range s.vars[ref-1] {
+		body.Variables[i] = Variable{Name: v.Name, Value: inspect(v.Value)}
+	}
+	return body, nil
+}
+
+func (s *Server) evaluate(args EvaluateArguments) (interface{}, error) {
+	s.lock.Lock()
+	p := s.paused
+	s.lock.Unlock()
+	if p == nil {
+		return nil, errors.New("not paused")
+	}
+	frame := 0
+	if args.FrameID != 0 {
+		frame = args.FrameID - 1
+	}
+	result, err := p.Eval(frame, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateResponseBody{Result: inspect(result)}, nil
+}
+
+func inspect(evaler parser.Evaler) string {
+	if evaler == nil {
+		return "(unset)"
+	}
+	return strings.TrimSpace(util.ToInspect(evaler))
+}
+
+// outputWriter sends what is written as output events.
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	err := w.s.event("output", OutputEventBody{Category: w.category, Output: string(p)})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -1 +1,282 @@
+// Package encode implements the binary bytecode format (.coab) for compiled programs.
+//
+// A file starts with Magic and a version number, followed by the position of
+// the compile environment, the instructions and their source map (see
+// compile.SourceMap):
+//
+//	magic     "COAB"
+//	version   uvarint
+//	pos       position
+//	insts     uvarint count, then (opcode byte, A varint, B operand) each
+//	positions uvarint count, then position each
+//	runs      uvarint count, then (start uvarint, position index varint) each
+//
+// The constants of the compile environment are not stored, as the compiler
+// never registers any: constants are operands of the instructions.
+//
+// Strings are a uvarint length followed by the bytes, positions are a string
+// (filename) followed by the offset, line and column as varints. An operand
+// is a tag byte (see the operand* constants) followed by its payload.
 package encode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/compile"
+)
+
+// Magic is the first bytes of every encoded program.
+const Magic = "COAB"
+
+// Version is the version of the format written and read.
+const Version = 1
+
+// ErrMagic is returned when decoding data that does not start with Magic.
+var ErrMagic = errors.New("not a compiled coa program (bad magic)")
+
+// VersionError is returned when decoding data with an unsupported version.
+type VersionError struct {
+	Version uint64
+}
+
+func (v *VersionError) Error() string {
+	return fmt.Sprintf("unsupported format version %d (supported: %d)", v.Version, Version)
+}
+
+const (
+	operandNil byte = iota
+	operandString
+	operandInt
+	operandFloat
+	operandRune
+	operandPos
+)
+
+// Program is a compiled program as stored in a .coab file.
+type Program struct {
+	Env   *compile.CompileEnv
+	Insts compile.Instructions
+}
+
+// IsEncoded reports whether data starts with Magic.
+func IsEncoded(data []byte) bool { return bytes.HasPrefix(data, []byte(Magic)) }
+
+// Encode writes p to w.
+func Encode(w io.Writer, p *Program) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.raw([]byte(Magic))
+	e.uvarint(Version)
+	e.pos(p.Env.Pos)
+	e.uvarint(uint64(len(p.Insts)))
+	for i, inst := range p.Insts {
+		e.raw([]byte{byte(inst.Opcode)})
+		e.varint(int64(inst.A))
+		if err := e.operand(inst.B); err != nil {
+			return fmt.Errorf("+%03x: %w", i, err)
+		}
+	}
+	m := p.Insts.SourceMap()
+	e.uvarint(uint64(len(m.Positions)))
+	for _, pos := range m.Positions {
+		e.pos(pos)
+	}
+	e.uvarint(uint64(len(m.Runs)))
+	for _, run := range m.Runs {
+		e.uvarint(uint64(run.Start))
+		e.varint(int64(run.Pos))
+	}
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *encoder) raw(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) uvarint(v uint64) { e.raw(e.buf[:binary.PutUvarint(e.buf[:], v)]) }
+
+func (e *encoder) varint(v int64) { e.raw(e.buf[:binary.PutVarint(e.buf[:], v)]) }
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.raw([]byte(s))
+}
+
+func (e *encoder) pos(p lexer.Position) {
+	e.string(p.Filename)
+	e.varint(int64(p.Offset))
+	e.varint(int64(p.Line))
+	e.varint(int64(p.Column))
+}
+
+func (e *encoder) operand(b interface{}) error {
+	switch b := b.(type) {
+	case nil:
+		e.raw([]byte{operandNil})
+	case string:
+		e.raw([]byte{operandString})
+		e.string(b)
+	case int:
+		e.raw([]byte{operandInt})
+		e.varint(int64(b))
+	case float64:
+		e.raw([]byte{operandFloat})
+		binary.LittleEndian.PutUint64(e.buf[:8], math.Float64bits(b))
+		e.raw(e.buf[:8])
+	case rune:
+		e.raw([]byte{operandRune})
+		e.varint(int64(b))
+	case lexer.Position:
+		e.raw([]byte{operandPos})
+		e.pos(b)
+	default:
+		return fmt.Errorf("cannot encode operand of type %T", b)
+	}
+	return nil
+}
+
+// Decode reads a program written by Encode from r.
+func Decode(r io.Reader) (*Program, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != Magic {
+		return nil, ErrMagic
+	}
+	version := d.uvarint()
+	if d.err == nil && version != Version {
+		return nil, &VersionError{Version: version}
+	}
+	env := compile.NewEnv(d.pos())
+	n := d.uvarint()
+	// counts are not trusted, so nothing is allocated up front
+	insts := make(compile.Instructions, 0)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		var inst compile.Instruction
+		inst.Opcode = compile.Opcode(d.byte())
+		inst.A = int(d.varint())
+		inst.B = d.operand()
+		insts = append(insts, inst)
+	}
+	insts.SetSourceMap(d.sourceMap())
+	if d.err != nil {
+		if d.err == io.EOF {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("decode: %w", d.err)
+	}
+	return &Program{Env: env, Insts: insts}, nil
+}
+
+func (d *decoder) sourceMap() *compile.SourceMap {
+	m := new(compile.SourceMap)
+	n := d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		m.Positions = append(m.Positions, d.pos())
+	}
+	n = d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		m.Runs = append(m.Runs, compile.SourceRun{Start: int(d.uvarint()), Pos: int(d.varint())})
+	}
+	return m
+}
+
+type decoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	var b byte
+	b, d.err = d.r.ReadByte()
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var v int64
+	v, d.err = binary.ReadVarint(d.r)
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > math.MaxInt32 {
+		d.err = fmt.Errorf("string of %d bytes too long", n)
+		return ""
+	}
+	// the string grows as it is read, so a bad length fails at the end of the input
+	b := new(strings.Builder)
+	_, d.err = io.CopyN(b, d.r, int64(n))
+	return b.String()
+}
+
+func (d *decoder) pos() lexer.Position {
+	return lexer.Position{
+		Filename: d.string(),
+		Offset:   int(d.varint()),
+		Line:     int(d.varint()),
+		Column:   int(d.varint()),
+	}
+}
+
+func (d *decoder) operand() interface{} {
+	switch tag := d.byte(); tag {
+	case operandNil:
+		return nil
+	case operandString:
+		return d.string()
+	case operandInt:
+		return int(d.varint())
+	case operandFloat:
+		var b [8]byte
+		if d.err == nil {
+			_, d.err = io.ReadFull(d.r, b[:])
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+	case operandRune:
+		return rune(d.varint())
+	case operandPos:
+		return d.pos()
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown operand tag %d", tag)
+		}
+		return nil
+	}
+}
@@ -1,6 +1,7 @@
 package errs
 
 import (
+	"errors"
 	"fmt"
 	"github.com/alecthomas/participle/v2/lexer"
 	"gitlab.com/coalang/go-coa/try2/util"
@@ -33,6 +34,26 @@ func (errs Errors) Error() string {
 	return re
 }
 
+// Is reports whether any of errs is target (see errors.Is).
+func (errs Errors) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of errs that matches target (see errors.As).
+func (errs Errors) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 func AppendERT(err error, frame ERTFrame) *ERT {
 	if e, ok := err.(*ERT); ok {
 		e.frames = append(e.frames, frame)
@@ -67,6 +88,11 @@ func (e *ERT) Error() string {
 	return e.String()
 }
 
+func (e *ERT) Unwrap() error { return e.Err }
+
+// Frames returns the frames of e, innermost call first.
+func (e *ERT) Frames() []ERTFrame { return e.frames }
+
 type ERTFrame struct {
 	Pos  lexer.Position
 	Call string
@@ -0,0 +1,274 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// maxCycle is the maximum number of frames of recursion collapsed by NewReport.
+const maxCycle = 4
+
+// Framed is an error with the calls it happened in, such as *ERT.
+type Framed interface {
+	error
+	Frames() []ERTFrame
+	// Frames returns the calls, innermost first.
+}
+
+// Report is an error prepared for showing, e.g. by Renderer or as JSON for
+// tooling.
+type Report struct {
+	Message string        `json:"message"`
+	Frames  []ReportFrame `json:"frames"`
+	// Frames are the calls the error happened in, innermost first.
+}
+
+// ReportFrame is a call of a Report.
+type ReportFrame struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Offset   int    `json:"offset"`
+	Call     string `json:"call,omitempty"`
+	Repeat   int    `json:"repeat,omitempty"`
+	// Repeat is how many more times the last Cycle frames (ending with this one)
+	// were repeated, e.g. by recursion. The repetitions are not in the Report.
+	Cycle int `json:"cycle,omitempty"`
+}
+
+// Pos returns the position of f.
+func (f ReportFrame) Pos() lexer.Position {
+	return lexer.Position{Filename: f.Filename, Offset: f.Offset, Line: f.Line, Column: f.Column}
+}
+
+// Message returns the message of the innermost error wrapped by err, leaving out
+// the traces and positions of the errors wrapping it.
+func Message(err error) string {
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return err.Error()
+		}
+		err = inner
+	}
+}
+
+// NewReports returns a Report for each of the errors of err if it is Errors, or
+// for err otherwise.
+func NewReports(err error) []*Report {
+	var errs Errors
+	if errors.As(err, &errs) && len(errs) > 1 {
+		re := make([]*Report, 0, len(errs))
+		for _, err := range errs {
+			if err != nil {
+				re = append(re, NewReport(err))
+			}
+		}
+		return re
+	}
+	return []*Report{NewReport(err)}
+}
+
+// NewReport returns a Report of err, with the frames of the Framed errors it
+// wraps. The message is of the error wrapped by the innermost one.
+// Errors without frames are reported at their Position, if they have one (e.g.
+// syntax errors).
+func NewReport(err error) *Report {
+	frames := make([]ERTFrame, 0)
+	for {
+		var f Framed
+		if !errors.As(err, &f) {
+			break
+		}
+		// frames of inner errors are of inner calls, and f.Frames() may be the
+		// frames of f itself, so it is copied
+		frames = append(append([]ERTFrame(nil), f.Frames()...), frames...)
+		inner := errors.Unwrap(f)
+		if inner == nil {
+			break
+		}
+		err = inner
+	}
+	message := err.Error()
+	var p interface{ Position() lexer.Position }
+	if len(frames) == 0 && errors.As(err, &p) {
+		pos := p.Position()
+		frames = append(frames, ERTFrame{Pos: pos})
+		// the position is shown with the frame
+		message = strings.TrimPrefix(message, pos.String()+": ")
+	}
+	r := &Report{Message: message, Frames: make([]ReportFrame, len(frames))}
+	for i, f := range frames {
+		r.Frames[i] = ReportFrame{
+			Filename: f.Pos.Filename,
+			Line:     f.Pos.Line,
+			Column:   f.Pos.Column,
+			Offset:   f.Pos.Offset,
+			Call:     f.Call,
+		}
+	}
+	r.Frames = collapse(r.Frames)
+	return r
+}
+
+// collapse replaces consecutive repetitions of up to maxCycle frames with the
+// first one, preferring the cycle which removes the most frames.
+func collapse(frames []ReportFrame) []ReportFrame {
+	re := make([]ReportFrame, 0, len(frames))
+	for i := 0; i < len(frames); {
+		cycle, repeat := 0, 0
+		for k := 1; k <= maxCycle; k++ {
+			n := 0
+			for i+(n+2)*k <= len(frames) && sameFrames(frames[i:i+k], frames[i+(n+1)*k:i+(n+2)*k]) {
+				n++
+			}
+			if n*k > repeat*cycle {
+				cycle, repeat = k, n
+			}
+		}
+		if repeat == 0 {
+			re = append(re, frames[i])
+			i++
+			continue
+		}
+		re = append(re, frames[i:i+cycle]...)
+		re[len(re)-1].Repeat = repeat
+		re[len(re)-1].Cycle = cycle
+		i += (repeat + 1) * cycle
+	}
+	return re
+}
+
+func sameFrames(a, b []ReportFrame) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteJSON writes the reports of err (see NewReports) to w as a JSON object
+// with the key "errors".
+func WriteJSON(w io.Writer, err error) error {
+	return json.NewEncoder(w).Encode(struct {
+		Errors []*Report `json:"errors"`
+	}{NewReports(err)})
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorError = "\x1b[1;31m"
+	colorInfo  = "\x1b[1;34m"
+)
+
+// Renderer writes errors as reports for humans, with the source line and a
+// caret under the column of each frame.
+type Renderer struct {
+	Color bool
+	// Color makes Render use ANSI colors, e.g. when writing to a terminal.
+	ReadFile func(filename string) ([]byte, error)
+	// ReadFile reads the sources of frames. Sources are not shown if nil or if
+	// reading fails.
+
+	sources map[string][]string
+}
+
+// Render writes the reports of err (see NewReports) to w.
+func (r *Renderer) Render(w io.Writer, err error) error {
+	b := new(strings.Builder)
+	for i, report := range NewReports(err) {
+		if i != 0 {
+			b.WriteString("\n")
+		}
+		r.render(b, report)
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (r *Renderer) render(b *strings.Builder, report *Report) {
+	fmt.Fprintf(b, "%s %s\n", r.color(colorError, "error:"), report.Message)
+	width := 0
+	for _, f := range report.Frames {
+		if n := len(strconv.Itoa(f.Line)); n > width {
+			width = n
+		}
+	}
+	gutter := strings.Repeat(" ", width)
+	for _, f := range report.Frames {
+		fmt.Fprintf(b, "%s%s %s\n", gutter, r.color(colorInfo, "-->"), f.Pos())
+		if line, ok := r.line(f.Filename, f.Line); ok {
+			fmt.Fprintf(b, "%s %s\n", gutter, r.color(colorInfo, "|"))
+			fmt.Fprintf(b, "%s %s %s\n", r.color(colorInfo, fmt.Sprintf("%*d", width, f.Line)), r.color(colorInfo, "|"), line)
+			fmt.Fprintf(b, "%s %s %s%s\n", gutter, r.color(colorInfo, "|"), caretIndent(line, f.Column), r.color(colorError, "^"))
+		}
+		if f.Call != "" {
+			fmt.Fprintf(b, "%s %s in %s\n", gutter, r.color(colorInfo, "="), firstLine(f.Call))
+		}
+		if f.Repeat != 0 {
+			fmt.Fprintf(b, "%s %s last %d frame(s) repeated %d more time(s)\n", gutter, r.color(colorInfo, "..."), f.Cycle, f.Repeat)
+		}
+	}
+}
+
+func (r *Renderer) color(color, s string) string {
+	if !r.Color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// line returns the line numbered n of the file filename.
+func (r *Renderer) line(filename string, n int) (string, bool) {
+	if r.ReadFile == nil {
+		return "", false
+	}
+	if r.sources == nil {
+		r.sources = map[string][]string{}
+	}
+	lines, ok := r.sources[filename]
+	if !ok {
+		data, err := r.ReadFile(filename)
+		if err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		r.sources[filename] = lines
+	}
+	if n < 1 || n > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+// caretIndent returns the indent of a caret under the column column of line,
+// keeping tabs so that the caret lines up.
+func caretIndent(line string, column int) string {
+	b := new(strings.Builder)
+	for i, c := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if c == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return b.String()
+}
+
+// firstLine returns the first line of s, with an ellipsis if there are more.
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i] + " …"
+	}
+	return s
+}
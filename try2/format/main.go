@@ -0,0 +1,255 @@
+// Package format prints Coa source code in a canonical form, keeping comments.
+//
+// Elements that start on the line the previous element ends on are kept on one
+// line, separated by single spaces. When a call, list or block spans lines, every
+// other line of elements is put on its own line, indented once more than the line
+// the container starts on, and the closer goes on its own line:
+//
+//	(@if
+//		(@eq x 0) 0
+//		x
+//	)
+//
+// Calls and lists keep the elements on the line of the opener, blocks do not.
+// A container whose elements are all on its first line is kept on that line,
+// even when its last element spans lines (e.g. (@def f {...})).
+// Runs of blank lines are reduced to one, and comments are kept in place.
+package format
+
+import (
+	"math"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// Source returns the formatted form of the Coa source code src.
+func Source(filename string, src []byte) ([]byte, error) {
+	if len(src) != 0 && src[len(src)-1] != '\n' {
+		// a comment at the end of the source needs a newline
+		src = append(src[:len(src):len(src)], '\n')
+	}
+	root := &parser.Nodes{}
+	err := parser.Parser.ParseBytes(filename, src, root)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := parser.Lex(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	p := newPrinter(tokens)
+	root.Accept(p)
+	return p.bytes(), nil
+}
+
+// printer prints nodes as it visits them.
+type printer struct {
+	b strings.Builder
+
+	text map[int]string
+	// text is the source of the token at each offset.
+
+	ends map[int]int
+	// ends is the line the token (or container, for openers) at each offset ends on.
+
+	closers map[int]int
+	// closers is the offset of the closer for the opener at each offset.
+
+	pos lexer.Position
+	// pos is the position of the node being visited.
+
+	comments []parser.Token
+	// comments are the comments not printed yet.
+
+	indent int
+	// indent is the indentation of the current output line.
+
+	last int
+	// last is the source line the last printed token ends on.
+
+	lineEnded bool
+	// lineEnded is true if the current output line ends with a comment.
+}
+
+var _ parser.Visitor = new(printer)
+
+func newPrinter(tokens []parser.Token) *printer {
+	p := &printer{
+		text:     map[int]string{},
+		ends:     map[int]int{},
+		closers:  map[int]int{},
+		comments: make([]parser.Token, 0),
+	}
+	opens := make([]parser.Token, 0)
+	for _, token := range tokens {
+		switch token.Kind {
+		case "Comment", "LongComment":
+			p.comments = append(p.comments, token)
+			continue
+		case "OParen", "OBrack", "OBrace":
+			opens = append(opens, token)
+		case "CParen", "CBrack", "CBrace":
+			if len(opens) != 0 {
+				// the parser already checked that brackets match
+				p.ends[opens[len(opens)-1].Pos.Offset] = token.Pos.Line
+				p.closers[opens[len(opens)-1].Pos.Offset] = token.Pos.Offset
+				opens = opens[:len(opens)-1]
+			}
+		default:
+			p.ends[token.Pos.Offset] = token.Pos.Line + strings.Count(token.Value, "\n")
+		}
+		p.text[token.Pos.Offset] = token.Value
+	}
+	return p
+}
+
+func (p *printer) bytes() []byte {
+	if p.b.Len() != 0 {
+		p.b.WriteString("\n")
+	}
+	return []byte(p.b.String())
+}
+
+// newline starts a new output line indented indent times, with a blank line
+// before it if the source had one before line.
+func (p *printer) newline(indent, line int) {
+	p.lineEnded = false
+	p.indent = indent
+	if p.b.Len() == 0 {
+		return
+	}
+	p.b.WriteString("\n")
+	if line > p.last+1 {
+		p.b.WriteString("\n")
+	}
+	p.b.WriteString(strings.Repeat(util.IndentString, indent))
+}
+
+// flushComments prints the comments before offset.
+// A comment on the line of the last printed token stays at the end of that line,
+// others are put on their own lines indented indent times.
+func (p *printer) flushComments(offset, indent int) {
+	for len(p.comments) != 0 && p.comments[0].Pos.Offset < offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		text := strings.TrimRight(c.Value, " \t\r\n")
+		if c.Pos.Line == p.last && !p.lineEnded && p.b.Len() != 0 {
+			p.b.WriteString(" ")
+		} else {
+			p.newline(indent, c.Pos.Line)
+		}
+		p.b.WriteString(text)
+		p.last = c.Pos.Line + strings.Count(text, "\n")
+		p.lineEnded = true
+	}
+}
+
+// seq prints nodes, keeping those that start on the line the previous one ends on
+// together.
+// If inline, nodes on line (the line of the opener) are printed right away.
+// Returns whether any nodes were put on new lines.
+func (p *printer) seq(nodes []parser.Node, indent, line int, inline bool) (broke bool) {
+	for i := range nodes {
+		n := &nodes[i]
+		switch {
+		case i == 0 && inline && n.Pos.Line == line:
+		case i != 0 && n.Pos.Line == p.last:
+			p.b.WriteString(" ")
+		default:
+			p.flushComments(n.Pos.Offset, indent)
+			p.newline(indent, n.Pos.Line)
+			broke = true
+		}
+		n.Accept(p)
+	}
+	return
+}
+
+// container prints a call, list or block starting at pos.
+func (p *printer) container(pos lexer.Position, nodes []parser.Node, inline bool) {
+	open := p.text[pos.Offset]
+	end := p.ends[pos.Offset]
+	indent := p.indent
+	p.b.WriteString(open)
+	p.last = pos.Line
+	spaced := len(nodes) != 0 && nodes[0].Pos.Line == pos.Line && needsSpace(open, p.text[nodes[0].Pos.Offset])
+	if spaced {
+		p.b.WriteString(" ")
+	}
+	if end == pos.Line {
+		// single line (no comments either, as they end lines)
+		for i := range nodes {
+			if i != 0 {
+				p.b.WriteString(" ")
+			}
+			nodes[i].Accept(p)
+		}
+		if spaced && open == "[" {
+			// like "[ m ]", so that the space does not look like a typo
+			p.b.WriteString(" ")
+		}
+	} else {
+		broke := p.seq(nodes, indent+1, pos.Line, inline)
+		p.flushComments(p.closers[pos.Offset], indent+1)
+		if broke || p.lineEnded {
+			p.newline(indent, end)
+		}
+	}
+	p.b.WriteString(closer(open))
+	p.last = end
+}
+
+// needsSpace returns whether open must be followed by a space before the text of
+// the first node: maps always have one (e.g. "[m 'a' 1]"), and lists need one if
+// they would be lexed as maps otherwise (e.g. "[" and "m" would make "[m").
+func needsSpace(open, first string) bool {
+	switch open {
+	case "[m":
+		return true
+	case "[":
+		return first[0] == 'm'
+	default:
+		return false
+	}
+}
+
+func closer(open string) string {
+	switch open[0] {
+	case '(':
+		return ")"
+	case '[':
+		return "]"
+	case '{':
+		return "}"
+	default:
+		panic("unknown opener " + open)
+	}
+}
+
+func (p *printer) VisitNodes(n *parser.Nodes) {
+	p.seq(n.Content, 0, 0, false)
+	p.flushComments(math.MaxInt32, 0)
+}
+
+func (p *printer) VisitNode(n *parser.Node) {
+	p.pos = n.Pos
+	n.Select().(parser.Thing).Accept(p)
+}
+
+// scalar prints the scalar at p.pos as written, keeping e.g. escapes and the
+// formatting of numbers.
+func (p *printer) scalar() {
+	p.b.WriteString(p.text[p.pos.Offset])
+	p.last = p.ends[p.pos.Offset]
+}
+
+func (p *printer) VisitNumber(*parser.Number) { p.scalar() }
+func (p *printer) VisitID(*parser.ID)         { p.scalar() }
+func (p *printer) VisitString(*parser.String) { p.scalar() }
+func (p *printer) VisitRune(*parser.Rune)     { p.scalar() }
+func (p *printer) VisitCall(c *parser.Call)   { p.container(c.Pos, c.Content.Content, true) }
+func (p *printer) VisitBlock(b *parser.Block) { p.container(b.Pos, b.Content.Content, false) }
+func (p *printer) VisitList(l *parser.List)   { p.container(l.Pos, l.Content.Content, true) }
@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"strings"
+)
+
+// Doc is the documentation of a builtin.
+type Doc struct {
+	Signature string
+	// Signature is how the builtin is used, e.g. (@add a b) or @true.
+
+	Text string
+}
+
+// BuiltinDocs returns the docs of the builtins, parsed from main/builtin.coa
+// (embedded by gen_builtin.sh).
+func BuiltinDocs() map[string]Doc { return ParseDocs(builtinSrc) }
+
+// ParseDocs parses documentation in the format of builtin.coa.
+// Each builtin has a line with its signature followed by a comment, which
+// continues on the indented comment-only lines after it.
+// Other comment lines (e.g. section headings) are ignored.
+func ParseDocs(src string) map[string]Doc {
+	docs := map[string]Doc{}
+	last := ""
+	for _, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			last = ""
+		case strings.HasPrefix(trimmed, "#"):
+			if last == "" || trimmed == line {
+				last = ""
+				continue
+			}
+			doc := docs[last]
+			doc.Text += "\n" + strings.TrimSpace(trimmed[1:])
+			docs[last] = doc
+		default:
+			signature, text := trimmed, ""
+			if i := strings.Index(trimmed, "#"); i != -1 {
+				signature, text = strings.TrimSpace(trimmed[:i]), strings.TrimSpace(trimmed[i+1:])
+			}
+			fields := strings.Fields(strings.TrimPrefix(signature, "("))
+			if len(fields) == 0 {
+				last = ""
+				continue
+			}
+			last = strings.TrimSuffix(fields[0], ")")
+			docs[last] = Doc{Signature: signature, Text: text}
+		}
+	}
+	return docs
+}
+
+// String returns d as Markdown.
+func (d Doc) String() string {
+	return "```coa\n" + d.Signature + "\n```\n" + d.Text
+}
@@ -0,0 +1,167 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// document is an open text document.
+type document struct {
+	uri  string
+	text string
+
+	root *parser.Nodes
+	// root is nil if text does not parse.
+
+	tokens []parser.Token
+	// tokens is nil if text does not lex.
+
+	diagnostics []Diagnostic
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, diagnostics: make([]Diagnostic, 0)}
+	d.tokens, _ = parser.Lex(uri, []byte(text))
+	root := &parser.Nodes{}
+	err := parser.Parser.ParseString(uri, text, root)
+	if err != nil {
+		d.diagnoseParse(err)
+		return d
+	}
+	d.root = root
+	d.diagnoseCheck()
+	return d
+}
+
+func (d *document) diagnoseParse(err error) {
+	var perr participle.Error
+	if !errors.As(err, &perr) {
+		d.diagnose(lexer.Position{}, 0, err.Error())
+		return
+	}
+	length := 0
+	var uerr participle.UnexpectedTokenError
+	if errors.As(err, &uerr) && !uerr.Unexpected.EOF() {
+		length = len(uerr.Unexpected.Value)
+	}
+	d.diagnose(perr.Position(), length, perr.Message())
+}
+
+// diagnoseCheck reports variables that are used before being defined (see parser.Check).
+func (d *document) diagnoseCheck() {
+	defer func() {
+		// the IDUses of some natives assume well-formed calls
+		if r := recover(); r != nil {
+			d.diagnose(lexer.Position{}, 0, fmt.Sprintf("checking: %v", r))
+		}
+	}()
+	err := parser.Check(d.root)
+	if err == nil {
+		return
+	}
+	var uerr *parser.UndefinedError
+	if !errors.As(err, &uerr) {
+		d.diagnose(lexer.Position{}, 0, err.Error())
+		return
+	}
+	for _, name := range uerr.Names {
+		pos, length := uerr.Pos, 0
+		for _, t := range d.tokens {
+			if t.Kind == "ID" && t.Value == name && t.Pos.Offset >= uerr.Pos.Offset {
+				pos, length = t.Pos, len(t.Value)
+				break
+			}
+		}
+		d.diagnose(pos, length, fmt.Sprintf("variable %s is not defined", name))
+	}
+}
+
+func (d *document) diagnose(pos lexer.Position, length int, message string) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Range:    d.rangeOf(pos.Offset, length),
+		Severity: SeverityError,
+		Source:   "coa",
+		Message:  message,
+	})
+}
+
+// rangeOf returns the range of length bytes at offset.
+func (d *document) rangeOf(offset, length int) Range {
+	return Range{Start: d.position(offset), End: d.position(offset + length)}
+}
+
+// position returns the position of the byte at offset.
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	lineStart := strings.LastIndex(d.text[:offset], "\n") + 1
+	return Position{
+		Line:      strings.Count(d.text[:lineStart], "\n"),
+		Character: len(utf16.Encode([]rune(d.text[lineStart:offset]))),
+	}
+}
+
+// offset returns the offset of the byte at pos.
+func (d *document) offset(pos Position) int {
+	offset := 0
+	for i := 0; i < pos.Line; i++ {
+		j := strings.IndexByte(d.text[offset:], '\n')
+		if j == -1 {
+			return len(d.text)
+		}
+		offset += j + 1
+	}
+	for n := 0; n < pos.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		n += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+// idAt returns the ID token around offset.
+func (d *document) idAt(offset int) (parser.Token, bool) {
+	for _, t := range d.tokens {
+		if t.Kind == "ID" && t.Pos.Offset <= offset && offset <= t.Pos.Offset+len(t.Value) {
+			return t, true
+		}
+	}
+	return parser.Token{}, false
+}
+
+// defs returns the positions of the names of all (@def name value) in d, in order.
+func (d *document) defs() map[string][]lexer.Position {
+	defs := map[string][]lexer.Position{}
+	if d.root != nil {
+		walkCalls(d.root.Content, func(c *parser.Call) {
+			content := c.Content.Content
+			if len(content) >= 2 && content[0].ID != nil && content[0].ID.Content == "@def" && content[1].ID != nil {
+				defs[content[1].ID.Content] = append(defs[content[1].ID.Content], content[1].Pos)
+			}
+		})
+	}
+	return defs
+}
+
+// walkCalls calls f with every call in nodes, outer calls first.
+func walkCalls(nodes []parser.Node, f func(c *parser.Call)) {
+	for _, n := range nodes {
+		switch {
+		case n.Call != nil:
+			f(n.Call)
+			walkCalls(n.Call.Content.Content, f)
+		case n.Block != nil:
+			walkCalls(n.Block.Content.Content, f)
+		case n.List != nil:
+			walkCalls(n.List.Content.Content, f)
+		}
+	}
+}
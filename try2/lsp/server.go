@@ -0,0 +1,229 @@
+// Package lsp implements a language server for Coa over stdio.
+//
+// The server reports parse errors and variables used before being defined,
+// shows the docs of builtins on hover, jumps to the @def of a name, and
+// completes builtin and defined names.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"sync"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// Server is a language server.
+type Server struct {
+	Docs map[string]Doc
+	// Docs are the docs of builtins (see ParseDocs).
+
+	documents map[string]*document
+	w         io.Writer
+	writeLock sync.Mutex
+}
+
+func NewServer(docs map[string]Doc) *Server {
+	if docs == nil {
+		docs = map[string]Doc{}
+	}
+	return &Server{
+		Docs:      docs,
+		documents: map[string]*document{},
+	}
+}
+
+// Serve handles the messages read from r and writes responses and notifications
+// to w, until the exit notification or the end of r.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	br := bufio.NewReader(r)
+	for {
+		m, err := ReadMessage(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var rerr *ResponseError
+		if errors.As(err, &rerr) {
+			err = s.write(&Message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: rerr})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(m)
+		if m.ID == nil {
+			// notification
+			if err != nil {
+				log.Printf("%s: %s", m.Method, err)
+			}
+			continue
+		}
+		if err != nil {
+			if !errors.As(err, &rerr) {
+				rerr = &ResponseError{Code: codeInternalError, Message: err.Error()}
+			}
+			err = s.write(&Message{JSONRPC: "2.0", ID: m.ID, Error: rerr})
+		} else {
+			err = s.write(&response{JSONRPC: "2.0", ID: m.ID, Result: result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) write(v interface{}) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return WriteMessage(s.w, v)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&Message{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+func (s *Server) handle(m *Message) (interface{}, error) {
+	switch m.Method {
+	case "initialize":
+		result := InitializeResult{}
+		result.ServerInfo.Name = "coa"
+		result.Capabilities.TextDocumentSync = 1 // full
+		result.Capabilities.HoverProvider = true
+		result.Capabilities.DefinitionProvider = true
+		result.Capabilities.CompletionProvider.TriggerCharacters = []string{"@"}
+		return result, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.open(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: make([]Diagnostic, 0),
+		})
+	case "textDocument/hover":
+		return s.positionRequest(m, s.hover)
+	case "textDocument/definition":
+		return s.positionRequest(m, s.definition)
+	case "textDocument/completion":
+		return s.positionRequest(m, s.completion)
+	default:
+		return nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + m.Method}
+	}
+}
+
+func unmarshalParams(m *Message, params interface{}) error {
+	err := json.Unmarshal(m.Params, params)
+	if err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// open sets the text of the document at uri and publishes its diagnostics.
+func (s *Server) open(uri, text string) error {
+	d := newDocument(uri, text)
+	s.documents[uri] = d
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: d.diagnostics,
+	})
+}
+
+func (s *Server) positionRequest(m *Message, f func(d *document, offset int) interface{}) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := unmarshalParams(m, &params); err != nil {
+		return nil, err
+	}
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: "document not open: " + params.TextDocument.URI}
+	}
+	return f(d, d.offset(params.Position)), nil
+}
+
+func (s *Server) hover(d *document, offset int) interface{} {
+	t, ok := d.idAt(offset)
+	if !ok {
+		return nil
+	}
+	doc, ok := s.Docs[t.Value]
+	if !ok {
+		return nil
+	}
+	r := d.rangeOf(t.Pos.Offset, len(t.Value))
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: doc.String()},
+		Range:    &r,
+	}
+}
+
+// definition returns the location of the last @def of the name at offset before
+// offset, or of the first one if there is none before.
+func (s *Server) definition(d *document, offset int) interface{} {
+	t, ok := d.idAt(offset)
+	if !ok {
+		return nil
+	}
+	defs := d.defs()[t.Value]
+	if len(defs) == 0 {
+		return nil
+	}
+	def := defs[0]
+	for _, pos := range defs {
+		if pos.Offset <= offset {
+			def = pos
+		}
+	}
+	return &Location{URI: d.uri, Range: d.rangeOf(def.Offset, len(t.Value))}
+}
+
+func (s *Server) completion(d *document, _ int) interface{} {
+	items := make([]CompletionItem, 0)
+	for name := range parser.NewBase() {
+		item := CompletionItem{Label: name, Kind: CompletionFunction}
+		if doc, ok := s.Docs[name]; ok {
+			item.Detail = doc.Signature
+			item.Documentation = doc.Text
+		}
+		items = append(items, item)
+	}
+	for name := range d.defs() {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionVariable})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
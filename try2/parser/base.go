@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -89,11 +88,25 @@ func newBase() map[string]Evaler {
 		}, OptionArgsPrefix(TypeBecomesString))),
 
 		"@time_now": NewNative(util.Info{Resources: []util.ResourceDef{{"os.time", -1}}}, func(env IEnv, args []Evaler) (Evaler, error) {
+			if d := env.Deterministic2(); d != nil {
+				return &Time{Time: d.Now()}, nil
+			}
 			return &Time{Time: time.Now()}, nil
 		}, OptionArgs()),
 		"@time_sleep": NewNative(util.InfoPure, func(env IEnv, args []Evaler) (Evaler, error) {
-			time.Sleep(time.Duration(float64(*(args[0].(*Number))) * float64(time.Second)))
-			return nil, nil
+			duration := time.Duration(float64(*(args[0].(*Number))) * float64(time.Second))
+			if d := env.Deterministic2(); d != nil {
+				d.Sleep(duration)
+				return nil, checkContext(env, env.Pos2())
+			}
+			t := time.NewTimer(duration)
+			defer t.Stop()
+			select {
+			case <-t.C:
+				return nil, nil
+			case <-env.Context().Done():
+				return nil, checkContext(env, env.Pos2())
+			}
 		}, OptionArgs(TypeNumber)),
 
 		"@sys_os":   NewString(runtime.GOOS),
@@ -114,10 +127,11 @@ func newBase() map[string]Evaler {
 				return nil, err
 			}
 			if !ok {
-				return nil, fmt.Errorf("failed assertion: %s", args[1].(BecomesString).BecomeString())
+				return nil, &RaisedError{Kind: KindAssert, Message: args[1].(BecomesString).BecomeString()}
 			}
 			return args[0], nil
 		}, OptionArgs(TypeAny, TypeBecomesString)),
+		"@test": NewNative(util.InfoPure, runTest, OptionArgs(TypeBecomesString, TypeCallable)),
 
 		"@filter": NewNative(util.InfoPure, func(env IEnv, args []Evaler) (Evaler, error) {
 			nodes := args[0].(HasNodes).Nodes()
@@ -166,8 +180,10 @@ func newBase() map[string]Evaler {
 		}, OptionArgs(TypeBecomesString)),
 
 		"@error": NewNative(util.InfoPure, func(env IEnv, args []Evaler) (Evaler, error) {
-			return nil, fmt.Errorf("error: %s", args[0].(*String).Content)
+			return nil, &RaisedError{Kind: KindError, Message: args[0].(*String).Content}
 		}, OptionArgs(TypeString)),
+		"@try":      NewNative(util.InfoPure, runTry, OptionArgs(TypeCallable, TypeCallable)),
+		"@error_is": NewNative(util.InfoPure, runErrorIs, OptionArgs(TypeErrorValue, TypeBecomesString)),
 		"@continue": nativeSpecial("@continue", idProviderNone, idProviderNone, NewNative(util.InfoPure, func(env IEnv, args []Evaler) (Evaler, error) {
 			return nil, ErrContinue
 		}, OptionNone)),
@@ -213,7 +229,8 @@ func newBase() map[string]Evaler {
 			return args[1], nil
 		}, OptionArgs(TypeID, TypeAny))),
 		"@mod": nativeSpecial("@mod", func(c *Call) []string {
-			return c.Content.Content[2].Select().IDUses()
+			// the variable must be defined before it is modified
+			return append(c.Content.Content[1].Select().IDUses(), c.Content.Content[2].Select().IDUses()...)
 		}, func(c *Call) []string {
 			return []string{c.Content.Content[1].Select().(*ID).Content}
 		}, NewNative(util.InfoPure, func(env IEnv, args []Evaler) (Evaler, error) {
@@ -252,6 +269,10 @@ func newBase() map[string]Evaler {
 			results := make([]Node, 0)
 			var result Evaler
 			for {
+				err := checkContext(inner, GetPos(callable))
+				if err != nil {
+					return nil, err
+				}
 				eval, err := Eval(condition, inner)
 				if err != nil {
 					return nil, err
@@ -270,6 +291,8 @@ func newBase() map[string]Evaler {
 						break
 					}
 					if errors.Is(err, ErrContinue) {
+						// a continued iteration has no result, also in the VM
+						result = nil
 						contThis = true
 						goto iter
 					}
@@ -300,6 +323,10 @@ func newBase() map[string]Evaler {
 			results := make([]Node, 0)
 			var result Evaler
 			for {
+				err := checkContext(inner, GetPos(callable))
+				if err != nil {
+					return nil, err
+				}
 				eval, err := Eval(condition, inner)
 				if err != nil {
 					return nil, err
@@ -675,10 +702,15 @@ func newBase() map[string]Evaler {
 		}, OptionArgs(TypeBecomesNumberLike)),
 
 		"@http_get": NewNative(util.Info{Resources: []util.ResourceDef{{"http", 0}}}, func(env IEnv, args []Evaler) (Evaler, error) {
-			got, err := (&http.Client{Timeout: 10 * time.Second}).Get(args[0].(BecomesString).BecomeString())
+			req, err := http.NewRequestWithContext(env.Context(), http.MethodGet, args[0].(BecomesString).BecomeString(), nil)
 			if err != nil {
 				return nil, err
 			}
+			got, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer got.Body.Close()
 			body, err := io.ReadAll(got.Body)
 			if err != nil {
 				return nil, err
@@ -731,22 +763,35 @@ func newBase() map[string]Evaler {
 		}, OptionArgs(TypeBecomesString)),
 
 		"@io_out": NewNative(util.Info{Resources: []util.ResourceDef{{"io.stdout", -1}}}, func(env IEnv, args []Evaler) (Evaler, error) {
-			wrote, err := os.Stdout.WriteString(args[0].(BecomesString).BecomeString())
+			wrote, err := io.WriteString(env.Stdio2().Writer(), args[0].(BecomesString).BecomeString())
 			if err != nil {
 				return nil, err
 			}
 			return NewNumber(float64(wrote)), nil
 		}, OptionArgs(TypeBecomesString)),
 		"@io_outln": NewNative(util.Info{Resources: []util.ResourceDef{{"io.stdout", -1}}}, func(env IEnv, args []Evaler) (Evaler, error) {
-			wrote, err := os.Stdout.WriteString(args[0].(BecomesString).BecomeString() + "\n")
+			wrote, err := io.WriteString(env.Stdio2().Writer(), args[0].(BecomesString).BecomeString()+"\n")
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(float64(wrote)), nil
+		}, OptionArgs(TypeBecomesString)),
+		"@io_err": NewNative(util.Info{Resources: []util.ResourceDef{{"io.stderr", -1}}}, func(env IEnv, args []Evaler) (Evaler, error) {
+			wrote, err := io.WriteString(env.Stdio2().ErrWriter(), args[0].(BecomesString).BecomeString())
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(float64(wrote)), nil
+		}, OptionArgs(TypeBecomesString)),
+		"@io_errln": NewNative(util.Info{Resources: []util.ResourceDef{{"io.stderr", -1}}}, func(env IEnv, args []Evaler) (Evaler, error) {
+			wrote, err := io.WriteString(env.Stdio2().ErrWriter(), args[0].(BecomesString).BecomeString()+"\n")
 			if err != nil {
 				return nil, err
 			}
 			return NewNumber(float64(wrote)), nil
 		}, OptionArgs(TypeBecomesString)),
 		"@io_in": NewNative(util.Info{[]util.ResourceDef{{"io.stdin", -1}}}, func(env IEnv, args []Evaler) (Evaler, error) {
-			reader := bufio.NewReader(os.Stdin)
-			read, err := reader.ReadString(byte(*(args[0].(*Rune))))
+			read, err := env.Stdio2().Reader().ReadString(byte(*(args[0].(*Rune))))
 			if err != nil {
 				return nil, err
 			}
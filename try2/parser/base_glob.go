@@ -23,7 +23,7 @@ func (g *Globber) Inspect() string                        { return g.String() }
 func (g *Globber) IDUses() []string                       { return nil }
 func (g *Globber) IDSets() []string                       { return nil }
 func (g *Globber) Call(env IEnv, args []Evaler) (results Evaler, err error) {
-	args, err = OptionArgs(TypeBecomesString)(env, args)
+	args, err = OptionArgs(TypeBecomesString).Apply(env, args)
 	if err != nil {
 		return nil, err
 	}
@@ -23,7 +23,7 @@ func (r *Regexer) Inspect() string                        { return r.String() }
 func (r *Regexer) IDUses() []string                       { return nil }
 func (r *Regexer) IDSets() []string                       { return nil }
 func (r *Regexer) Call(env IEnv, args []Evaler) (results Evaler, err error) {
-	args, err = OptionArgs(TypeBecomesString)(env, args)
+	args, err = OptionArgs(TypeBecomesString).Apply(env, args)
 	if err != nil {
 		return nil, err
 	}
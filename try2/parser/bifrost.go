@@ -6,16 +6,19 @@ var Bifrost = &bifrost{}
 
 type bifrost struct{}
 
+// Profile writes the dump of env to its Stdio.
 func (b *bifrost) Profile(env IEnv) {
-	fmt.Println(env.Dump())
+	fmt.Fprintln(env.Stdio2().Writer(), env.Dump())
 }
 
+// Peek writes the uses, sets and resources of evalers to the Stdio of env.
 func (b *bifrost) Peek(env IEnv, evalers []Evaler) {
+	w := env.Stdio2().Writer()
 	dump := env.Dump()
 	dump.Vars = nil
-	fmt.Println(dump)
+	fmt.Fprintln(w, dump)
 	for i, evaler := range evalers {
-		fmt.Printf("%d:\n\tuses: %s\n\tsets: %s\n\tresources: %s\n\t%s\n",
+		fmt.Fprintf(w, "%d:\n\tuses: %s\n\tsets: %s\n\tresources: %s\n\t%s\n",
 			i, evaler.IDUses(), evaler.IDSets(), evaler.Info(env), evaler.Inspect())
 	}
 }
@@ -59,3 +59,6 @@ func (b *Block) String() string       { return "{" + b.Content.String() + "}" }
 func (b *Block) Inspect() string      { return "{" + b.Content.Inspect() + "}" }
 func (b *Block) Pos_() lexer.Position { return b.Pos }
 func (b *Block) runParallel() bool    { return len(b.O) != 2 }
+
+// RunParallel reports whether the nodes of the block may be evaluated in parallel.
+func (b *Block) RunParallel() bool { return b.runParallel() }
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/alecthomas/participle/v2/lexer"
 	"gitlab.com/coalang/go-coa/try2/errs"
@@ -58,6 +58,28 @@ func (c *Call) Eval(env IEnv) (evaler Evaler, err error) {
 	if len(c.Content.Content) == 0 {
 		return nil, fmt.Errorf("%s: blank call", c.Pos)
 	}
+	err = checkContext(env, c.Pos)
+	if err != nil {
+		return nil, err
+	}
+	if d := debuggerOf(env); d != nil {
+		l := localOf(env)
+		var prev lexer.Position
+		if l.call != nil {
+			prev = l.call.pos
+		}
+		l.call = &debugCall{pos: c.Pos, call: c, env: env, parent: l.call}
+		err = d.At(&envPaused{l.call}, c.Pos, prev, env.StackLen())
+		if err != nil {
+			return nil, err
+		}
+		env = withLocal(env, l)
+	}
+	limits := env.Limits2()
+	err = limits.CheckCallDepth(c.Pos, env.StackLen())
+	if err != nil {
+		return nil, err
+	}
 	ee, err := c.ee(env)
 	if err != nil {
 		return nil, err
@@ -87,26 +109,59 @@ func (c *Call) Eval(env IEnv) (evaler Evaler, err error) {
 		env.Printf("%#v", ee.Info(env).Resources)
 	}
 
-	resources, stringsArgs := util.EvalResources(ee.Info(env).Resources, StringsSliceEvalers(args))
-	rs := util.EvalResources2(ee.Info(env).Resources, StringsSliceEvalers(args))
-	if !env.CheckResources(rs) {
-		brs := env.BadResources(rs)
-		brsString := make([]string, len(brs))
-		for _, i := range brs {
-			brsString = append(brsString, rs[i].String())
+	if _, ok := ee.(*Native); ok {
+		// only the resources of natives refer to their own arguments
+		rs, err := CallResources(ee.Info(env).Resources, args)
+		if err != nil {
+			return nil, err
+		}
+		err = GuardResources(env, c.Pos, rs)
+		if err != nil {
+			return nil, err
 		}
-		env.Printf("deferred evaluating %s due to usage of %d resource(s):%s", c.Pos, len(rs), strings.Join(brsString, " "))
-		return c2, nil
 	}
-	env.LockResources(c.Pos, resources, stringsArgs)
+	resources, stringsArgs := util.EvalResources(ee.Info(env).Resources, StringsSliceEvalers(args))
+	if t := hostOf(env).Tracer; t != nil && len(resources) != 0 {
+		start := time.Now()
+		env.LockResources(c.Pos, resources, stringsArgs)
+		t.Span(localOf(env).track, "lock", "lock resources", start, map[string]interface{}{
+			"pos":       c.Pos.String(),
+			"resources": resourcesNames(resources, stringsArgs),
+		})
+	} else {
+		env.LockResources(c.Pos, resources, stringsArgs)
+	}
 	defer env.UnlockResources(c.Pos, resources, stringsArgs)
-	result, err := ee.Call(env, args)
+	var callEnv IEnv = env
+	if p := hostOf(env).Profile; p != nil {
+		l := localOf(env)
+		f := p.Enter(l.frame, callSite(c))
+		defer f.Exit()
+		l.frame = f
+		callEnv = withLocal(env, l)
+	}
+	var result Evaler
+	n, native := ee.(*Native)
+	if native {
+		// the resources were guarded above, at the position of the call
+		result, err = n.f(callEnv, args)
+	} else {
+		result, err = ee.Call(callEnv, args)
+	}
 	if err != nil {
 		return
 	}
 	if result == nil {
+		if native {
+			// natives may return nothing (e.g. @time_sleep), like in the VM
+			return nil, nil
+		}
 		return nil, errors.New("result of call is nil")
 	}
+	err = limits.CheckValue(c.Pos, result)
+	if err != nil {
+		return nil, err
+	}
 	//env.printf("call re\t%s %s →  %s", c.Pos, c.Inspect(), result.Inspect())
 	return result, nil
 }
@@ -131,7 +186,10 @@ func (c *Call) idUsesFor() []string {
 	if id, ok := c.Content.Content[0].Select().(*ID); ok {
 		if f, ok := idUsesProviders[id.Content]; ok {
 			if f != nil {
-				return f(c)
+				if uses := f(c); uses != nil {
+					return uses
+				}
+				return []string{} // e.g. (@def a 1) uses nothing
 			}
 		}
 	}
@@ -2,11 +2,19 @@ package parser
 
 import (
 	"fmt"
+	"github.com/alecthomas/participle/v2/lexer"
 	"gitlab.com/coalang/go-coa/try2/util"
 	"strings"
 )
 
-func check(nodes *Nodes) error {
+func init() {
+	// special natives register their id providers when a base is made, and
+	// Check may be called before any Env exists
+	newBase()
+}
+
+// Check checks that nodes only use variables defined before them.
+func Check(nodes *Nodes) error {
 	err := checkEvalers(nodes.Select())
 	if err != nil {
 		return err
@@ -22,8 +30,19 @@ func checkEvalers(evalers []Evaler) (err error) {
 		uses, sets = util.NoBuiltins(uses), util.NoBuiltins(sets)
 		uses, _ = util.NoOverlap(util.NoBuiltins(uses), util.NoBuiltins(sets))
 		if len(uses) > 0 {
-			return fmt.Errorf("%s: index %d: required variables not defined: %s", GetPos(evaler), i, strings.Join(uses, " "))
+			return &UndefinedError{Pos: GetPos(evaler), Index: i, Names: uses}
 		}
 	}
 	return nil
 }
+
+// UndefinedError is returned by Check when a node uses variables not defined before it.
+type UndefinedError struct {
+	Pos   lexer.Position
+	Index int
+	Names []string
+}
+
+func (e *UndefinedError) Error() string {
+	return fmt.Sprintf("%s: index %d: required variables not defined: %s", e.Pos, e.Index, strings.Join(e.Names, " "))
+}
@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// CanceledError is returned when the context of an Env is done while evaluating
+// (see Host.Ctx).
+// It wraps the error of the context (context.Canceled or
+// context.DeadlineExceeded).
+type CanceledError struct {
+	Pos lexer.Position
+	Err error
+}
+
+func (e *CanceledError) Error() string { return fmt.Sprintf("%s: canceled: %s", e.Pos, e.Err) }
+func (e *CanceledError) Unwrap() error { return e.Err }
+
+// checkContext returns a *CanceledError if the context of env is done.
+func checkContext(env IEnv, pos lexer.Position) error {
+	if err := env.Context().Err(); err != nil {
+		return &CanceledError{Pos: pos, Err: err}
+	}
+	return nil
+}
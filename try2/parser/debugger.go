@@ -0,0 +1,311 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// ErrQuit is returned by evaluating when the Debugger was told to quit.
+var ErrQuit = errors.New("quit debugging")
+
+// Action is how evaluating continues after pausing.
+type Action int
+
+const (
+	// ActionContinue runs until the next breakpoint.
+	ActionContinue Action = iota
+	// ActionStepInto pauses at the next line, including lines of callees.
+	ActionStepInto
+	// ActionStepOver pauses at the next line of the current call or its callers.
+	ActionStepOver
+	// ActionStepOut pauses once the current call returns.
+	ActionStepOut
+	// ActionQuit stops evaluating with ErrQuit.
+	ActionQuit
+)
+
+// StopReason is why evaluating paused.
+type StopReason string
+
+const (
+	StopPause      StopReason = "pause"
+	StopBreakpoint StopReason = "breakpoint"
+	StopStep       StopReason = "step"
+)
+
+// Stop is a pause of evaluating.
+type Stop struct {
+	Pos    lexer.Position
+	Reason StopReason
+}
+
+// Breakpoint is a line of a file to pause at.
+type Breakpoint struct {
+	Filename string
+	Line     int
+}
+
+// Paused is evaluating paused by a Debugger, which can be inspected until
+// OnStop returns.
+type Paused interface {
+	Frames() []Frame
+	// Frames returns the call stack, latest call first.
+	Eval(frame int, src string) (Evaler, error)
+	// Eval evaluates src in the frame at index frame of Frames, without pausing.
+}
+
+// Frame is a frame of the call stack of a Paused.
+type Frame struct {
+	Name   string
+	Pos    lexer.Position
+	Scopes []Scope
+	// Scopes are the variables visible in the frame, innermost first.
+}
+
+// Scope is variables of a Frame.
+type Scope struct {
+	Name string
+	Vars []Var
+}
+
+// Var is a variable of a Scope.
+// Value is nil for variables not set yet.
+type Var struct {
+	Name  string
+	Value Evaler
+}
+
+// Debugger pauses evaluating at breakpoints and after steps, at the first call
+// (or OpPos in the VM) of each line.
+// Strands evaluated in parallel pause one at a time.
+type Debugger struct {
+	OnStop func(p Paused, stop Stop) Action
+	// OnStop is called with the paused evaluation in the goroutine evaluating,
+	// and returns how to continue.
+
+	stopLock sync.Mutex
+	// stopLock is locked while calling OnStop, so that strands stop one at a
+	// time. It is not lock, so that OnStop can wait for the breakpoints to be
+	// changed from another goroutine.
+
+	lock        sync.Mutex
+	breakpoints map[Breakpoint]struct{}
+	action      Action
+	depth       int
+	// depth is the depth when action was returned by OnStop.
+	paused bool
+	// paused is true if Pause was called since the last stop.
+}
+
+// NewDebugger returns a Debugger calling onStop, without breakpoints.
+func NewDebugger(onStop func(p Paused, stop Stop) Action) *Debugger {
+	return &Debugger{OnStop: onStop, breakpoints: map[Breakpoint]struct{}{}}
+}
+
+// Pause makes evaluating pause at the next line.
+// Pause may be called while evaluating, e.g. from another goroutine.
+func (d *Debugger) Pause() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.paused = true
+}
+
+// AddBreakpoint adds a breakpoint at b.
+func (d *Debugger) AddBreakpoint(b Breakpoint) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.breakpoints[b] = struct{}{}
+}
+
+// RemoveBreakpoint removes the breakpoint at b, if any.
+func (d *Debugger) RemoveBreakpoint(b Breakpoint) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.breakpoints, b)
+}
+
+// SetBreakpoints replaces the breakpoints in the file filename with ones at lines.
+func (d *Debugger) SetBreakpoints(filename string, lines []int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for b := range d.breakpoints {
+		if b.Filename == filename {
+			delete(d.breakpoints, b)
+		}
+	}
+	for _, line := range lines {
+		d.breakpoints[Breakpoint{Filename: filename, Line: line}] = struct{}{}
+	}
+}
+
+// Breakpoints returns the breakpoints, sorted by file and line.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	re := make([]Breakpoint, 0, len(d.breakpoints))
+	for b := range d.breakpoints {
+		re = append(re, b)
+	}
+	sort.Slice(re, func(i, j int) bool {
+		if re[i].Filename != re[j].Filename {
+			return re[i].Filename < re[j].Filename
+		}
+		return re[i].Line < re[j].Line
+	})
+	return re
+}
+
+// At is called by engines before evaluating at pos, after prev in the same
+// call, with depth calls or scopes. At pauses if needed, and returns ErrQuit if
+// OnStop says to quit.
+// A nil *Debugger never pauses.
+func (d *Debugger) At(p Paused, pos, prev lexer.Position, depth int) error {
+	if d == nil {
+		return nil
+	}
+	newLine := pos.Filename != prev.Filename || pos.Line != prev.Line
+
+	d.lock.Lock()
+	var reason StopReason
+	switch {
+	case d.paused:
+		reason = StopPause
+	case d.stepDone(depth, newLine):
+		reason = StopStep
+	case newLine && d.hasBreakpoint(pos):
+		reason = StopBreakpoint
+	default:
+		d.lock.Unlock()
+		return nil
+	}
+	d.paused = false
+	d.lock.Unlock()
+
+	d.stopLock.Lock()
+	defer d.stopLock.Unlock()
+	action := ActionContinue
+	if d.OnStop != nil {
+		action = d.OnStop(p, Stop{Pos: pos, Reason: reason})
+	}
+	d.lock.Lock()
+	d.action = action
+	d.depth = depth
+	d.lock.Unlock()
+	if action == ActionQuit {
+		return ErrQuit
+	}
+	return nil
+}
+
+// stepDone returns true if the last step is done at depth.
+// d must be locked.
+func (d *Debugger) stepDone(depth int, newLine bool) bool {
+	switch d.action {
+	case ActionStepInto:
+		return newLine
+	case ActionStepOver:
+		return depth < d.depth || depth == d.depth && newLine
+	case ActionStepOut:
+		return depth < d.depth
+	default:
+		return false
+	}
+}
+
+// hasBreakpoint returns true if there is a breakpoint at pos.
+// d must be locked.
+func (d *Debugger) hasBreakpoint(pos lexer.Position) bool {
+	_, ok := d.breakpoints[Breakpoint{Filename: pos.Filename, Line: pos.Line}]
+	return ok
+}
+
+// debuggerOf returns the Debugger of env, or nil if there is none or env is
+// evaluating for a Debugger (see envPaused.Eval).
+func debuggerOf(env IEnv) *Debugger {
+	if localOf(env).call.noDebug() {
+		return nil
+	}
+	return hostOf(env).Debugger
+}
+
+// debugCall is a call being evaluated by the interpreter with a Debugger.
+type debugCall struct {
+	pos    lexer.Position
+	call   *Call
+	env    IEnv
+	parent *debugCall
+	// parent is the call evaluating this call, or nil.
+	evaluating bool
+	// evaluating is true for a call made by Paused.Eval, whose callees must not
+	// pause.
+}
+
+func (c *debugCall) noDebug() bool { return c != nil && c.evaluating }
+
+// envPaused is the Paused of the interpreter, paused at the call c.
+type envPaused struct{ c *debugCall }
+
+func (p *envPaused) Frames() []Frame {
+	re := make([]Frame, 0)
+	for c := p.c; c != nil; c = c.parent {
+		re = append(re, Frame{Name: util.ToInspect(c.call), Pos: c.pos, Scopes: envScopes(c.env)})
+	}
+	return re
+}
+
+// envScopes returns the variables of env and its outer Envs except builtins,
+// innermost first.
+func envScopes(env IEnv) []Scope {
+	e, ok := envOf(env)
+	if !ok {
+		return nil
+	}
+	re := make([]Scope, 0)
+	for ; e != nil; e = e.outer {
+		e.varsLock.RLock()
+		vars := make([]Var, 0, len(e.vars))
+		for key, value := range e.vars {
+			if !util.IsBuiltin(key) {
+				vars = append(vars, Var{Name: key, Value: value})
+			}
+		}
+		e.varsLock.RUnlock()
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+		re = append(re, Scope{Name: "env " + e.Pos.String(), Vars: vars})
+	}
+	return re
+}
+
+func (p *envPaused) Eval(frame int, src string) (Evaler, error) {
+	c := p.c
+	for i := 0; i < frame && c != nil; i++ {
+		c = c.parent
+	}
+	if c == nil {
+		return nil, errors.New("frame out of range")
+	}
+	return EvalDebug(withLocal(c.env, local{call: &debugCall{evaluating: true}}), src)
+}
+
+// EvalDebug parses src and evaluates it in env for a Debugger, e.g. as a watch
+// expression, and returns the value of its last node.
+func EvalDebug(env IEnv, src string) (Evaler, error) {
+	root := Nodes{}
+	err := Parser.Parse("eval", bytes.NewBufferString(src), &root)
+	if err != nil {
+		return nil, err
+	}
+	var result Evaler
+	for _, n := range root.Select() {
+		result, err = Eval(n, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Deterministic makes evaluating reproducible, so that a given input always gives
+// the same output and interleaving bugs can be replayed by seed:
+// strands are run one at a time in an order picked by Seed instead of in parallel,
+// hooks are called synchronously, and @time_now and @time_sleep use a virtual
+// clock.
+// A nil *Deterministic is not deterministic.
+type Deterministic struct {
+	Seed  int64
+	Epoch time.Time
+	// Epoch is the first time returned by Now.
+	Tick time.Duration
+	// Tick is the time between the times returned by Now.
+
+	lock    sync.Mutex
+	rand    *rand.Rand
+	elapsed time.Duration
+}
+
+// NewDeterministic returns a Deterministic with seed, starting at the Unix epoch
+// and ticking one millisecond.
+func NewDeterministic(seed int64) *Deterministic {
+	return &Deterministic{Seed: seed, Epoch: time.Unix(0, 0).UTC(), Tick: time.Millisecond}
+}
+
+// Now returns the virtual time, and advances it by Tick.
+func (d *Deterministic) Now() time.Time {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.Epoch.Add(d.elapsed)
+	d.elapsed += d.Tick
+	return now
+}
+
+// Sleep advances the virtual time by duration, without waiting.
+func (d *Deterministic) Sleep(duration time.Duration) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.elapsed += duration
+}
+
+// Run runs tasks one at a time, each after the tasks it depends on, in an order
+// picked by the seed.
+// deps is the number of tasks each task depends on, and is modified.
+// run runs the task i and returns the tasks depending on it, or nil if they must
+// not be run.
+func (d *Deterministic) Run(deps []int, run func(i int) (reverseDeps []int)) {
+	ready := make([]int, 0, len(deps))
+	for i, n := range deps {
+		if n == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) != 0 {
+		j := d.intn(len(ready))
+		i := ready[j]
+		ready = append(ready[:j], ready[j+1:]...)
+		for _, rd := range run(i) {
+			deps[rd]--
+			if deps[rd] == 0 {
+				ready = append(ready, rd)
+			}
+		}
+	}
+}
+
+func (d *Deterministic) intn(n int) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.rand == nil {
+		d.rand = rand.New(rand.NewSource(d.Seed))
+	}
+	return d.rand.Intn(n)
+}
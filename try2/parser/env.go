@@ -24,7 +24,12 @@ type Env struct {
 	hooksLock      sync.Mutex
 	allowParallel  bool
 	ResourcesGuard ResourcesGuard
-	debug          bool
+	*Host
+	// Host is shared with inner Envs.
+	local *local
+	// local is the local state of the localEnv this Env was inherited from, if
+	// any.
+	debug bool
 }
 
 func (e *Env) AllowParallel2() bool { return e.allowParallel }
@@ -47,6 +52,7 @@ func NewEnv(pos lexer.Position, allowParallel bool) *Env {
 		vars:          newBase(),
 		allowParallel: allowParallel,
 		resources:     map[string]map[string]*sync.Mutex{},
+		Host:          new(Host),
 		debug:         true,
 	}
 }
@@ -97,29 +103,19 @@ func (e *Env) Dump() *EnvDump {
 	}
 }
 
+// checkResource returns whether the ResourcesGuards of e and all its outer Envs
+// allow r.
 func (e *Env) checkResource(r util.Resource) bool {
-	if e == nil {
-		return true
-	}
-	if e.ResourcesGuard == nil {
-		return true
+	for ; e != nil; e = e.outer {
+		if e.ResourcesGuard != nil && !e.ResourcesGuard.Allowed(r) {
+			return false
+		}
 	}
-	return e.ResourcesGuard.Allowed(r) && e.outer.checkResource(r)
+	return true
 }
 
 func (e *Env) CheckResources(rs []util.Resource) bool {
-	if e == nil {
-		return true
-	}
-	if e.ResourcesGuard == nil {
-		return true
-	}
-	for _, r := range rs {
-		if !e.ResourcesGuard.Allowed(r) {
-			return false
-		}
-	}
-	return e.outer.CheckResources(rs)
+	return len(e.BadResources(rs)) == 0
 }
 
 func (e *Env) BadResources(rs []util.Resource) []int {
@@ -180,6 +176,7 @@ func (e *Env) InheritLone(pos lexer.Position) IEnv {
 		allowParallel: e.allowParallel,
 		resources:     map[string]map[string]*sync.Mutex{},
 		outer:         e,
+		Host:          e.Host,
 		debug:         e.debug,
 	}
 }
@@ -193,6 +190,7 @@ func (e *Env) Inherit(pos lexer.Position) IEnv {
 		allowParallel: e.allowParallel,
 		resources:     map[string]map[string]*sync.Mutex{},
 		outer:         e,
+		Host:          e.Host,
 		debug:         e.debug,
 	}
 }
@@ -260,8 +258,8 @@ func (e *Env) MyKeys() []string {
 
 func (e *Env) Def(key string, evaler Evaler) {
 	e.varsLock.Lock()
-	defer e.varsLock.Unlock()
 	e.vars[key] = evaler
+	e.varsLock.Unlock()
 	e.callHooksConcurrent()
 }
 
@@ -272,7 +270,13 @@ func (e *Env) Mod(key string, evaler Evaler) {
 	e.Def(key, evaler)
 }
 
+// callHooksConcurrent calls the hooks in a new goroutine, or synchronously if
+// deterministic.
 func (e *Env) callHooksConcurrent() {
+	if e.Deterministic2() != nil {
+		e.callHooks()
+		return
+	}
 	go e.callHooks()
 }
 
@@ -293,8 +297,8 @@ func (e *Env) callHooks() {
 
 func (e *Env) AddHook(name string, f hook) {
 	e.hooksLock.Lock()
-	defer e.hooksLock.Unlock()
 	e.hooks = append(e.hooks, f)
 	e.hookNames = append(e.hookNames, name)
+	e.hooksLock.Unlock()
 	e.callHooksConcurrent()
 }
@@ -7,6 +7,23 @@ import (
 	"gitlab.com/coalang/go-coa/try2/util"
 )
 
+// PanicError is a panic recovered while evaluating, e.g. in a native.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("panic: %v", e.Value) }
+
+// evalRecover is Eval, but returns a *PanicError if evaluating panics.
+func evalRecover(evaler Evaler, env IEnv) (result Evaler, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r}
+		}
+	}()
+	return Eval(evaler, env)
+}
+
 type idProvider = func(*Call) []string
 
 func idProviderNone(_ *Call) []string {
@@ -57,10 +74,29 @@ func eval_(env IEnv, _ bool, evalers []Evaler) ([]Evaler, error) {
 		evalersIsPure(env, evalers) {
 		return evalParallel2(env, evalers)
 	} else {
+		if t := hostOf(env).Tracer; t != nil {
+			t.Instant(localOf(env).track, "series", "series", map[string]interface{}{
+				"pos":    env.Pos2().String(),
+				"nodes":  len(evalers),
+				"reason": seriesReason(env, evalers),
+			})
+		}
 		return evalSeries(env, evalers)
 	}
 }
 
+// seriesReason returns why evalers are evaluated in series, for tracing.
+func seriesReason(env IEnv, evalers []Evaler) string {
+	switch {
+	case !env.AllowParallel2():
+		return "parallel disabled"
+	case len(evalers) == 1:
+		return "single node"
+	default:
+		return "not pure"
+	}
+}
+
 func evalSeries(env IEnv, evalers []Evaler) ([]Evaler, error) {
 	if env.Debug2() {
 		env.Printf("series %d", len(evalers))
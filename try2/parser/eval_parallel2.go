@@ -8,15 +8,18 @@ import (
 	"time"
 
 	errs2 "gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/trace"
 	"gitlab.com/coalang/go-coa/try2/util"
 )
 
 type Strand = strand
 
 func CompileEvalers(keys []string, evalers []Evaler) ([]*strand, error) {
-	deps := getEvalersDeps(keys, evalers)
+	deps, err := getEvalersDeps(keys, evalers)
+	if err != nil {
+		return nil, err
+	}
 	strands := reverseDepsStrands(cleanStrands(getStrands(evalers, deps)))
-	var err error
 	strands, err = checkStrands(evalers, strands)
 	if err != nil {
 		return nil, err
@@ -25,7 +28,7 @@ func CompileEvalers(keys []string, evalers []Evaler) ([]*strand, error) {
 }
 
 func evalParallel2(env IEnv, evalers []Evaler) ([]Evaler, error) {
-	log.Println("evalParallel2", env, env.Keys())
+	log.Println("evalParallel2", env.Pos2(), env.Keys())
 	strands, err := CompileEvalers(env.Keys(), evalers)
 	if err != nil {
 		return nil, err
@@ -35,6 +38,11 @@ func evalParallel2(env IEnv, evalers []Evaler) ([]Evaler, error) {
 		if env.Debug2() {
 			env.Printf("sc %d", len(evalers))
 		}
+		if t := hostOf(env).Tracer; t != nil {
+			t.Instant(localOf(env).track, "parallel", "one strand", map[string]interface{}{
+				"pos": env.Pos2().String(),
+			})
+		}
 		s := strands[0]
 		for _, i := range s.Todo {
 			evalers[i], err = Eval(evalers[i], env)
@@ -46,8 +54,21 @@ func evalParallel2(env IEnv, evalers []Evaler) ([]Evaler, error) {
 	if env.Debug2() {
 		env.Printf("parallel %d", len(evalers))
 	}
-	r := runStrands(env, evalers, strands)
+	start := time.Now()
+	var r *runEnv
+	if d := env.Deterministic2(); d != nil {
+		r = newRunEnv(env, evalers, strands)
+		r.runDeterministic(d)
+	} else {
+		r = runStrands(env, evalers, strands)
+	}
 	err = r.waitResults(env, strands, evalers)
+	if r.tracer != nil {
+		r.tracer.Span(localOf(env).track, "parallel", "wait strands", start, map[string]interface{}{
+			"pos":     env.Pos2().String(),
+			"strands": len(strands),
+		})
+	}
 	if env.Debug2() {
 		env.Printf("parallel %d done", len(evalers))
 	}
@@ -168,7 +189,9 @@ const (
 	outerScopeEvalerIndex = -1
 )
 
-func getEvalersDeps(keys []string, evalers []Evaler) evalersDeps {
+// getEvalersDeps returns the evalers each evaler uses variables set by, or an
+// error if an evaler uses a variable set by none of them and not in keys.
+func getEvalersDeps(keys []string, evalers []Evaler) (evalersDeps, error) {
 	sDeps := evalersDeps{}
 	varIndexes := map[string]int{}
 	log.Println("keys", keys)
@@ -183,8 +206,7 @@ func getEvalersDeps(keys []string, evalers []Evaler) evalersDeps {
 		for _, use := range uses {
 			index, ok := varIndexes[use]
 			if !ok {
-				log.Println(use, GetPos(evaler))
-				panic("key use not found in varIndexes")
+				return nil, fmt.Errorf("%s: required variable not defined: %s", GetPos(evaler), use)
 			}
 			switch index {
 			case outerScopeEvalerIndex:
@@ -197,7 +219,7 @@ func getEvalersDeps(keys []string, evalers []Evaler) evalersDeps {
 			varIndexes[set] = i
 		}
 	}
-	return sDeps
+	return sDeps, nil
 }
 
 func cleanStrands(ss []*strand) (cleaned []*strand) {
@@ -352,6 +374,11 @@ type runEnv struct {
 	evalers             []Evaler
 	env                 IEnv
 	ch                  chan result
+
+	tracer *trace.Tracer
+	flows  [][]int64
+	// flows are the IDs of the trace flows from the deps of each strand, locked
+	// by strandsDepCountLock.
 }
 
 func newRunEnv(env IEnv, evalers []Evaler, ss []*strand) *runEnv {
@@ -364,6 +391,8 @@ func newRunEnv(env IEnv, evalers []Evaler, ss []*strand) *runEnv {
 		evalers:             evalers,
 		env:                 env,
 		ch:                  make(chan result, n),
+		tracer:              hostOf(env).Tracer,
+		flows:               make([][]int64, n),
 	}
 	for i, s := range ss {
 		re.strandsDepCount[i] = len(s.Deps)
@@ -395,7 +424,7 @@ func runStrands(env IEnv, evalers []Evaler, ss []*strand) *runEnv {
 		}
 	}
 	for _, i := range starts {
-		go r.runStartStrand(i)
+		go r.runStartStrand(i, r.newTrack())
 	}
 	//for i, s := range ss {
 	//	printStrand(env, evalers, i, s)
@@ -404,6 +433,18 @@ func runStrands(env IEnv, evalers []Evaler, ss []*strand) *runEnv {
 	return r
 }
 
+// runDeterministic runs the strands one at a time in the current goroutine, in
+// the order picked by d.
+func (r *runEnv) runDeterministic(d *Deterministic) {
+	track := localOf(r.env).track
+	d.Run(r.strandsDepCount[:len(r.ss)], func(strandI strandIndex) []strandIndex {
+		for _, i := range r.ss[strandI].Todo {
+			r.runEvaler(r.env, track, i)
+		}
+		return r.ss[strandI].ReverseDeps
+	})
+}
+
 func (r *runEnv) signalDepDone(env IEnv, strandI strandIndex) {
 	r.strandsDepCountLock[strandI].Lock()
 	defer r.strandsDepCountLock[strandI].Unlock()
@@ -416,9 +457,12 @@ func (r *runEnv) signalDepDone(env IEnv, strandI strandIndex) {
 	}
 }
 
-func (r *runEnv) signalDepDone2(env IEnv, strandI strandIndex) bool {
+func (r *runEnv) signalDepDone2(env IEnv, strandI strandIndex, flow int64) bool {
 	r.strandsDepCountLock[strandI].Lock()
 	defer r.strandsDepCountLock[strandI].Unlock()
+	if flow != 0 {
+		r.flows[strandI] = append(r.flows[strandI], flow)
+	}
 	r.strandsDepCount[strandI]--
 	if r.strandsDepCount[strandI] < 0 {
 		panic("strandI must have positive depCount")
@@ -426,33 +470,63 @@ func (r *runEnv) signalDepDone2(env IEnv, strandI strandIndex) bool {
 	return r.strandsDepCount[strandI] == 0
 }
 
-func (r *runEnv) runStartStrand(strandI strandIndex) {
+// newTrack returns a track for a goroutine running strands.
+func (r *runEnv) newTrack() trace.Track {
+	if r.tracer == nil {
+		return 0
+	}
+	return r.tracer.NewTrack("strands of " + r.env.Pos2().String())
+}
+
+func (r *runEnv) runStartStrand(strandI strandIndex, track trace.Track) {
 	prefix := fmt.Sprintf("[strand %d] ", strandI)
 	s := r.ss[strandI]
+	env := r.env
+	if r.tracer != nil {
+		// all deps are done, so flows is not modified anymore
+		for _, flow := range r.flows[strandI] {
+			r.tracer.FlowEnd(track, "parallel", "dep", flow)
+		}
+		l := localOf(env)
+		l.track = track
+		env = withLocal(env, l)
+	}
 	start := time.Now()
 	for _, i := range s.Todo {
-		r.runEvaler(i)
+		r.runEvaler(env, track, i)
 	}
 	took := time.Since(start)
 	r.ss[strandI].updateTime(took)
+	if r.tracer != nil {
+		r.tracer.Span(track, "parallel", fmt.Sprintf("strand %d", strandI), start, map[string]interface{}{
+			"pos":         r.env.Pos2().String(),
+			"todo":        s.Todo,
+			"deps":        s.Deps,
+			"reverseDeps": s.ReverseDeps,
+		})
+	}
 
 	lastRDI := len(s.ReverseDeps) - 1
 	for i, reverseDep := range s.ReverseDeps {
 		lastRD := i == lastRDI
-		rdLastDepDone := r.signalDepDone2(r.env, reverseDep)
+		var flow int64
+		if r.tracer != nil {
+			flow = r.tracer.FlowStart(track, "parallel", "dep")
+		}
+		rdLastDepDone := r.signalDepDone2(r.env, reverseDep, flow)
 		if rdLastDepDone {
 			if lastRD {
 				// takeover so we don't have to spawn superfluous goroutines
 				if r.env.Debug2() {
 					r.env.Printf("%stakeover %d", prefix, reverseDep)
 				}
-				r.runStartStrand(reverseDep)
+				r.runStartStrand(reverseDep, track)
 			} else {
 				// spawn
 				if r.env.Debug2() {
 					r.env.Printf("%sspawn %d", prefix, reverseDep)
 				}
-				go r.runStartStrand(reverseDep)
+				go r.runStartStrand(reverseDep, r.newTrack())
 			}
 		}
 	}
@@ -464,7 +538,7 @@ func (r *runEnv) runStrand(env IEnv, evalers []Evaler, ch chan<- result, strandI
 	env.Printf("runStrand start %d", strandI)
 	s := r.ss[strandI]
 	for _, i := range s.Todo {
-		r.runEvaler(i)
+		r.runEvaler(r.env, 0, i)
 	}
 	env.Printf("runStrand sendSignals %d", strandI)
 	for _, reverseDep := range s.ReverseDeps {
@@ -472,11 +546,24 @@ func (r *runEnv) runStrand(env IEnv, evalers []Evaler, ch chan<- result, strandI
 	}
 }
 
-func (r *runEnv) runEvaler(i evalerIndex) {
+// runEvaler evaluates the evaler at i in env, which is r.env with the local state
+// of the strand running it on track.
+func (r *runEnv) runEvaler(env IEnv, track trace.Track, i evalerIndex) {
 	var err error
 	start := time.Now()
-	r.evalers[i], err = Eval(r.evalers[i], r.env)
+	var node string
+	if r.tracer != nil {
+		// inspected before evaluating, as r.evalers[i] is replaced by the result
+		node = inspect(r.evalers[i])
+	}
+	// strands run in their own goroutines, where a panic would crash the host
+	r.evalers[i], err = evalRecover(r.evalers[i], env)
 	end := time.Now()
+	if r.tracer != nil {
+		r.tracer.Span(track, "parallel", fmt.Sprintf("node %d", i), start, map[string]interface{}{
+			"node": node,
+		})
+	}
 	r.ch <- result{
 		Index: i,
 		Error: err,
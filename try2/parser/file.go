@@ -9,7 +9,10 @@ import (
 	"time"
 )
 
-func (e *Env) LoadPathOnly(path string) (re *Nodes, err error) {
+func (e *Env) LoadPathOnly(path string) (re *Nodes, err error) { return ParsePath(path) }
+
+// ParsePath reads (from a URL or the local filesystem), parses and checks the file at path.
+func ParsePath(path string) (re *Nodes, err error) {
 	var data []byte
 	resp, err := (&http.Client{Timeout: 1 * time.Second}).Get(path)
 	if err != nil {
@@ -40,7 +43,7 @@ LocalFS:
 	if err != nil {
 		return nil, err
 	}
-	err = check(&root)
+	err = Check(&root)
 	if err != nil {
 		return nil, err
 	}
@@ -48,5 +51,8 @@ LocalFS:
 }
 func (e *Env) LoadPath(path string) (re Evaler, err error) {
 	root, err := e.LoadPathOnly(path)
+	if err != nil {
+		return nil, err
+	}
 	return root.Eval(e)
 }
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"context"
+
+	"gitlab.com/coalang/go-coa/try2/prof"
+	"gitlab.com/coalang/go-coa/try2/trace"
+)
+
+// Host is how the program embedding the interpreter evaluates, e.g. where natives
+// read and write and when to stop.
+// The Env made by NewEnv owns it, and the Envs inherited from that Env share it.
+// Nil fields are not used.
+type Host struct {
+	Tester Tester
+	// Tester runs the tests defined by (@test name block).
+	Stdio *Stdio
+	// Stdio is used by natives instead of os.Stdin, os.Stdout and os.Stderr.
+	Ctx context.Context
+	// Ctx cancels evaluating when done.
+	Limits *Limits
+	// Limits limits evaluating.
+	Profile *prof.Profile
+	// Profile profiles calls.
+	Deterministic *Deterministic
+	// Deterministic makes evaluating reproducible.
+	Debugger *Debugger
+	// Debugger pauses evaluating.
+	Tracer *trace.Tracer
+	// Tracer traces the scheduling of evaluating.
+}
+
+// noHost is the Host of IEnvs which are not Envs (e.g. of the VM), which use
+// their own settings.
+var noHost = new(Host)
+
+// hostOf returns the Host of env.
+func hostOf(env IEnv) *Host {
+	e, ok := envOf(env)
+	if !ok {
+		return noHost
+	}
+	return e.Host
+}
+
+func (e *Env) Stdio2() *Stdio                 { return e.Stdio }
+func (e *Env) Limits2() *Limits               { return e.Limits }
+func (e *Env) Deterministic2() *Deterministic { return e.Deterministic }
+
+// Context returns the Ctx of e, or context.Background() if it is nil.
+func (e *Env) Context() context.Context {
+	if e.Ctx != nil {
+		return e.Ctx
+	}
+	return context.Background()
+}
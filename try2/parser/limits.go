@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Limits limits evaluating, e.g. scripts which are not trusted (see Host.Limits).
+// Zero fields are not limited.
+type Limits struct {
+	Instructions int64
+	// Instructions is the maximum number of instructions executed by the VM,
+	// including those executed by strands.
+	CallDepth int
+	// CallDepth is the maximum depth of calls (see Env.StackLen), or of scopes in
+	// the VM.
+	ListLen int
+	// ListLen is the maximum length of lists made by calls.
+	StringSize int
+	// StringSize is the maximum size in bytes of strings made by calls.
+}
+
+// Names of limits in LimitErrors.
+const (
+	LimitInstructions = "instructions"
+	LimitCallDepth    = "call depth"
+	LimitListLen      = "list length"
+	LimitStringSize   = "string size"
+)
+
+// LimitError is returned when evaluating exceeds a limit of Limits.
+type LimitError struct {
+	Limit string
+	// Limit is the name of the limit exceeded, e.g. LimitCallDepth.
+	Max int64
+	Pos lexer.Position
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s limit exceeded (max %d)", e.Pos, e.Limit, e.Max)
+}
+
+// CheckCallDepth returns a *LimitError if depth exceeds the call depth limit of l.
+// l may be nil for no limits.
+func (l *Limits) CheckCallDepth(pos lexer.Position, depth int) error {
+	if l == nil || l.CallDepth == 0 || depth <= l.CallDepth {
+		return nil
+	}
+	return &LimitError{Limit: LimitCallDepth, Max: int64(l.CallDepth), Pos: pos}
+}
+
+// CheckValue returns a *LimitError if evaler is a list or string exceeding the
+// limits of l.
+// l may be nil for no limits.
+func (l *Limits) CheckValue(pos lexer.Position, evaler Evaler) error {
+	if l == nil {
+		return nil
+	}
+	switch evaler := evaler.(type) {
+	case *List:
+		if l.ListLen != 0 && evaler.Len() > l.ListLen {
+			return &LimitError{Limit: LimitListLen, Max: int64(l.ListLen), Pos: pos}
+		}
+	case *String:
+		if l.StringSize != 0 && len(evaler.Content) > l.StringSize {
+			return &LimitError{Limit: LimitStringSize, Max: int64(l.StringSize), Pos: pos}
+		}
+	}
+	return nil
+}
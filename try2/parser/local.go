@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/prof"
+	"gitlab.com/coalang/go-coa/try2/trace"
+)
+
+// local is the state of the goroutine evaluating in an Env, which differs
+// between goroutines evaluating in the same Env.
+type local struct {
+	frame *prof.Frame
+	// frame is the call being profiled.
+	track trace.Track
+	// track is the track of the goroutine in the trace.
+	call *debugCall
+	// call is the call being evaluated, if debugging.
+}
+
+// localOf returns the local state of env, which is of the nearest localEnv or
+// Env inherited by one.
+func localOf(env IEnv) local {
+	if le, ok := env.(*localEnv); ok {
+		return le.local
+	}
+	e, ok := env.(*Env)
+	if !ok {
+		return local{}
+	}
+	for ; e != nil; e = e.outer {
+		if e.local != nil {
+			return *e.local
+		}
+	}
+	return local{}
+}
+
+// envOf returns the *Env of env, unwrapping localEnvs.
+func envOf(env IEnv) (*Env, bool) {
+	for {
+		switch e := env.(type) {
+		case *Env:
+			return e, true
+		case *localEnv:
+			env = e.IEnv
+		default:
+			return nil, false
+		}
+	}
+}
+
+// localEnv is an IEnv evaluated in with different local state than the Env it
+// wraps, e.g. by a callee being profiled or a strand being traced.
+// Envs inherited from it keep the local state.
+type localEnv struct {
+	IEnv
+	local local
+}
+
+// withLocal returns env with the local state l.
+func withLocal(env IEnv, l local) IEnv {
+	if le, ok := env.(*localEnv); ok {
+		env = le.IEnv
+	}
+	return &localEnv{IEnv: env, local: l}
+}
+
+func (e *localEnv) Inherit(pos lexer.Position) IEnv {
+	return e.inherited(e.IEnv.Inherit(pos))
+}
+
+func (e *localEnv) InheritLone(pos lexer.Position) IEnv {
+	return e.inherited(e.IEnv.InheritLone(pos))
+}
+
+func (e *localEnv) inherited(inner IEnv) IEnv {
+	if inner, ok := inner.(*Env); ok {
+		l := e.local
+		inner.local = &l
+		return inner
+	}
+	return withLocal(inner, e.local)
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"bytes"
+
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
 	"github.com/alecthomas/participle/v2/lexer/stateful"
@@ -9,9 +11,18 @@ import (
 const allowed = `@$a-zA-Z_`
 const ident = `[` + allowed + `][` + allowed + `0-9]*`
 
-var lexerDef = lexer.Must(stateful.NewSimple([]stateful.Rule{
+var lexerDef = lexer.Must(stateful.NewSimple(append([]stateful.Rule{
 	{Name: "comment", Pattern: `#[^\n]*\n`}, // names starting with lowercase are elided
 	{Name: "longComment", Pattern: `##[^#]##`},
+}, lexerRules...)))
+
+// commentLexerDef is lexerDef, but keeps comments (see Lex).
+var commentLexerDef = lexer.Must(stateful.NewSimple(append([]stateful.Rule{
+	{Name: "Comment", Pattern: `#[^\n]*\n`},
+	{Name: "LongComment", Pattern: `##[^#]##`},
+}, lexerRules...)))
+
+var lexerRules = []stateful.Rule{
 	{Name: "space", Pattern: `[\s]+`},
 	{Name: "ID", Pattern: ident},
 	{Name: "String", Pattern: `\"(\\.|[^"\\])*\"`},
@@ -23,6 +34,35 @@ var lexerDef = lexer.Must(stateful.NewSimple([]stateful.Rule{
 	{Name: "CBrace", Pattern: `\}`},
 	{Name: "OParen", Pattern: `\(`},
 	{Name: "CParen", Pattern: `\)`},
-}))
+}
 
 var Parser = participle.MustBuild(&Nodes{}, participle.Lexer(lexerDef))
+
+// Token is a token of source code.
+type Token struct {
+	lexer.Token
+	Kind string
+	// Kind is the name of the lexer rule, e.g. "OParen", "Comment" or "LongComment".
+}
+
+// Lex splits src into tokens like Parser does, but keeps comments.
+// The EOF token is not included.
+func Lex(filename string, src []byte) ([]Token, error) {
+	l, err := commentLexerDef.Lex(filename, bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := lexer.ConsumeAll(l)
+	if err != nil {
+		return nil, err
+	}
+	kinds := lexer.SymbolsByRune(commentLexerDef)
+	re := make([]Token, 0, len(tokens))
+	for _, token := range tokens {
+		if token.EOF() {
+			break
+		}
+		re = append(re, Token{Token: token, Kind: kinds[token.Type]})
+	}
+	return re, nil
+}
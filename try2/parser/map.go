@@ -3,6 +3,7 @@ package parser
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/alecthomas/participle/v2/lexer"
 	"gitlab.com/coalang/go-coa/try2/util"
@@ -21,24 +22,18 @@ type Map struct {
 	iterI   int
 }
 
-func (m *Map) Len() int {
+// sortedKeys returns the keys of m in order, so that iterating is deterministic.
+func (m *Map) sortedKeys() []string {
 	if m.keys == nil || len(m.keys) != len(m.Content) {
-		m.keys = make([]string, 0, len(m.Content))
-		for key := range m.Content {
-			m.keys = append(m.keys, key)
-		}
+		m.keys = m.Keys()
 	}
-	return len(m.keys)
+	return m.keys
 }
 
+func (m *Map) Len() int { return len(m.sortedKeys()) }
+
 func (m *Map) Index(i int) (key, value Evaler) {
-	if m.keys == nil || len(m.keys) != len(m.Content) {
-		m.keys = make([]string, 0, len(m.Content))
-		for key := range m.Content {
-			m.keys = append(m.keys, key)
-		}
-	}
-	k := m.keys[i]
+	k := m.sortedKeys()[i]
 	return NewString(k), m.Content[k]
 }
 
@@ -84,15 +79,15 @@ func (m *Map) Info(env IEnv) util.Info {
 func (m *Map) Eval(_ IEnv) (result Evaler, err error) { return m, nil }
 func (m *Map) String() string {
 	re := "[m\n"
-	for key, val := range m.Content {
-		re += util.Indent((&String{Content: key}).String()+" "+val.String()) + "\n"
+	for _, key := range m.Keys() {
+		re += util.Indent((&String{Content: key}).String()+" "+m.Content[key].String()) + "\n"
 	}
 	return re[:len(re)-1] + "\n]"
 }
 func (m *Map) Inspect() string {
 	re := "[m\n"
-	for key, val := range m.Content {
-		re += util.Indent((&String{Content: key}).Inspect()+" "+val.Inspect()) + "\n"
+	for _, key := range m.Keys() {
+		re += util.Indent((&String{Content: key}).Inspect()+" "+m.Content[key].Inspect()) + "\n"
 	}
 	return re[:len(re)-1] + "\n]"
 }
@@ -117,5 +112,6 @@ func (m *Map) Keys() (keys []string) {
 	for key := range m.Content {
 		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 	return keys
 }
@@ -14,6 +14,7 @@ type Native struct {
 	f       NativeFunc
 	i       util.Info
 	special bool
+	options []Option
 }
 
 func (n *Native) MarshalJSON() ([]byte, error) {
@@ -31,10 +32,10 @@ func nativeSpecial(name string, uses, sets idProvider, native *Native) *Native {
 }
 
 func NewNative(info util.Info, native NativeFunc, options ...Option) *Native {
-	return &Native{i: info, f: func(env IEnv, args []Evaler) (Evaler, error) {
+	return &Native{i: info, options: options, f: func(env IEnv, args []Evaler) (Evaler, error) {
 		var err error
 		for _, option := range options {
-			args, err = option(env, args)
+			args, err = option.Apply(env, args)
 			if err != nil {
 				return nil, err
 			}
@@ -44,6 +45,16 @@ func NewNative(info util.Info, native NativeFunc, options ...Option) *Native {
 }
 
 func (n *Native) isSpecial() bool { return n.special }
+
+// Signature returns the Signature option of n, or nil if it has none.
+func (n *Native) Signature() *Signature {
+	for _, option := range n.options {
+		if s, ok := option.(*Signature); ok {
+			return s
+		}
+	}
+	return nil
+}
 func (n *Native) String() string {
 	i := n.i.String()
 	if i != "" {
@@ -51,9 +62,21 @@ func (n *Native) String() string {
 	}
 	return fmt.Sprintf("(@native %p%s)", n.f, i)
 }
-func (n *Native) Inspect() string                              { return n.String() }
-func (n *Native) Info(_ IEnv) util.Info                        { return n.i }
-func (n *Native) Eval(_ IEnv) (result Evaler, err error)       { return n, nil }
-func (n *Native) Call(env IEnv, args []Evaler) (Evaler, error) { return n.f(env, args) }
-func (n *Native) IDUses() []string                             { return nil }
-func (n *Native) IDSets() []string                             { return nil }
+func (n *Native) Inspect() string                        { return n.String() }
+func (n *Native) Info(_ IEnv) util.Info                  { return n.i }
+func (n *Native) Eval(_ IEnv) (result Evaler, err error) { return n, nil }
+func (n *Native) Call(env IEnv, args []Evaler) (Evaler, error) {
+	// Call.Eval guards calls in code itself, but natives may also be called by
+	// other natives (e.g. (@map list @file_remove)) or by the VM
+	rs, err := CallResources(n.i.Resources, args)
+	if err != nil {
+		return nil, err
+	}
+	err = GuardResources(env, env.Pos2(), rs)
+	if err != nil {
+		return nil, err
+	}
+	return n.f(env, args)
+}
+func (n *Native) IDUses() []string { return nil }
+func (n *Native) IDSets() []string { return nil }
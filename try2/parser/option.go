@@ -3,28 +3,38 @@ package parser
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"gitlab.com/coalang/go-coa/try2/util"
 )
 
-type Option func(env IEnv, args []Evaler) ([]Evaler, error)
+// Option checks (and may convert) the arguments of a Native before it is called.
+type Option interface {
+	Apply(env IEnv, args []Evaler) ([]Evaler, error)
+}
+
+// OptionFunc is an Option implemented by a function.
+type OptionFunc func(env IEnv, args []Evaler) ([]Evaler, error)
+
+func (f OptionFunc) Apply(env IEnv, args []Evaler) ([]Evaler, error) { return f(env, args) }
 
 var (
 	TypeID                = new(ID)
 	TypeBool              = new(Bool)
 	TypeNumber            = new(Number)
-	TypeNumberLike        = special(func(evaler Evaler) bool { _, ok := evaler.(NumberLike); return ok })
-	TypeBecomesNumberLike = special(func(evaler Evaler) bool { _, ok := evaler.(BecomesNumberLike); return ok })
+	TypeNumberLike        = special{"NumberLike", func(evaler Evaler) bool { _, ok := evaler.(NumberLike); return ok }}
+	TypeBecomesNumberLike = special{"BecomesNumberLike", func(evaler Evaler) bool { _, ok := evaler.(BecomesNumberLike); return ok }}
 	TypeString            = new(String)
-	TypeBecomesString     = special(func(evaler Evaler) bool { _, ok := evaler.(BecomesString); return ok })
-	TypeBecomesFloat64    = special(func(evaler Evaler) bool { _, ok := evaler.(BecomesFloat64); return ok })
+	TypeBecomesString     = special{"BecomesString", func(evaler Evaler) bool { _, ok := evaler.(BecomesString); return ok }}
+	TypeBecomesFloat64    = special{"BecomesFloat64", func(evaler Evaler) bool { _, ok := evaler.(BecomesFloat64); return ok }}
 	TypeRune              = new(Rune)
-	TypeCallable          = special(func(evaler Evaler) bool { _, ok := evaler.(Callable); return ok })
-	TypeAny               = special(func(Evaler) bool { return true })
+	TypeCallable          = special{"Callable", func(evaler Evaler) bool { _, ok := evaler.(Callable); return ok }}
+	TypeAny               = special{"Any", func(Evaler) bool { return true }}
 	TypeMap               = new(Map)
-	TypeHasNodes          = special(func(evaler Evaler) bool { _, ok := evaler.(HasNodes); return ok })
-	TypeIter              = special(func(evaler Evaler) bool { _, ok := evaler.(Iter); return ok })
-	TypeMapLike           = special(func(evaler Evaler) bool { _, ok := evaler.(MapLike); return ok })
+	TypeHasNodes          = special{"HasNodes", func(evaler Evaler) bool { _, ok := evaler.(HasNodes); return ok }}
+	TypeIter              = special{"Iter", func(evaler Evaler) bool { _, ok := evaler.(Iter); return ok }}
+	TypeMapLike           = special{"MapLike", func(evaler Evaler) bool { _, ok := evaler.(MapLike); return ok }}
+	TypeErrorValue        = new(ErrorValue)
 )
 
 type NumberLike interface {
@@ -63,110 +73,127 @@ type Iter interface {
 }
 
 func anyNilOf(ts ...interface{}) special {
-	return func(evaler Evaler) bool {
-		if evaler == nil {
-			return true
-		}
-		for _, t := range ts {
-			var argOk bool
-			if at, ok := t.(special); ok {
-				argOk = at(evaler)
-			} else {
-				argOk = util.ToString(reflect.TypeOf(t)) == util.ToString(reflect.TypeOf(evaler))
-			}
-			if argOk {
-				return true
-			}
-		}
-		return false
-	}
+	return special{"nil|" + typeNames(ts), func(evaler Evaler) bool {
+		return evaler == nil || matchesAny(ts, evaler)
+	}}
 }
 func anyOf(ts ...interface{}) special {
-	return func(evaler Evaler) bool {
-		if evaler == nil {
-			return false
-		}
-		for _, t := range ts {
-			var argOk bool
-			if at, ok := t.(special); ok {
-				argOk = at(evaler)
-			} else {
-				argOk = util.ToString(reflect.TypeOf(t)) == util.ToString(reflect.TypeOf(evaler))
-			}
-			if argOk {
-				return true
-			}
+	return special{typeNames(ts), func(evaler Evaler) bool {
+		return evaler != nil && matchesAny(ts, evaler)
+	}}
+}
+
+func matchesAny(ts []interface{}, evaler Evaler) bool {
+	for _, t := range ts {
+		if matches(t, evaler) {
+			return true
 		}
-		return false
 	}
+	return false
 }
 
-type special func(Evaler) bool
+// special is a type that is not a single Evaler type (e.g. an interface).
+type special struct {
+	name  string
+	match func(Evaler) bool
+}
 
-func OptionArgsPrefix(argTypes ...interface{}) Option {
-	return func(env IEnv, args []Evaler) (_ []Evaler, err error) {
-		if len(argTypes) > len(args) {
-			return nil, fmt.Errorf("wanted %s+, got %s (length)", util.StringSliceInterface(argTypes), StringSliceEvaler(args))
-		}
-		var arg Evaler
-		for i, argType := range argTypes {
-			arg = args[i]
-			var argOk bool
-			if at, ok := argType.(special); ok {
-				argOk = at(arg)
-			} else {
-				argOk = util.ToString(reflect.TypeOf(argType)) == util.ToString(reflect.TypeOf(arg))
-			}
-			if !argOk {
-				return nil, fmt.Errorf("wanted %s, got %s (%d)", util.StringSliceInterface(argTypes), StringSliceEvaler(args), i)
-			}
-		}
-		return args, nil
+// matches returns whether evaler has type t, either a special or a value of the type
+// (e.g. TypeNumber).
+func matches(t interface{}, evaler Evaler) bool {
+	if at, ok := t.(special); ok {
+		return at.match(evaler)
 	}
+	return util.ToString(reflect.TypeOf(t)) == util.ToString(reflect.TypeOf(evaler))
 }
 
-func OptionNone(_ IEnv, args []Evaler) ([]Evaler, error) {
-	if len(args) != 0 {
-		return nil, fmt.Errorf("wanted 0, got %s (length)", StringSliceEvaler(args))
+// typeName returns the name of t, either a special or a value of the type.
+func typeName(t interface{}) string {
+	if at, ok := t.(special); ok {
+		return at.name
+	}
+	if t == nil {
+		return "nil"
+	}
+	rt := reflect.TypeOf(t)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
 	}
-	return nil, nil
+	return rt.Name()
 }
 
-func OptionArgs(argTypes ...interface{}) Option {
-	return func(env IEnv, args []Evaler) (_ []Evaler, err error) {
-		if len(argTypes) != len(args) {
-			return nil, fmt.Errorf("wanted %s, got %s (length)", util.StringSliceInterface(argTypes), StringSliceEvaler(args))
-		}
-		var arg Evaler
-		for i, argType := range argTypes {
-			arg = args[i]
-			var argOk bool
-			if at, ok := argType.(special); ok {
-				argOk = at(arg)
-			} else {
-				argOk = util.ToString(reflect.TypeOf(argType)) == util.ToString(reflect.TypeOf(arg))
-			}
-			if !argOk {
-				return nil, fmt.Errorf("wanted %s, got %s (%d)", util.StringSliceInterface(argTypes), StringSliceEvaler(args), i)
-			}
-		}
-		return args, nil
+func typeNames(ts []interface{}) string {
+	names := make([]string, len(ts))
+	for i, t := range ts {
+		names[i] = typeName(t)
 	}
+	return strings.Join(names, "|")
 }
 
-func OptionVariadic(argType interface{}) Option {
-	return func(env IEnv, args []Evaler) ([]Evaler, error) {
+// Signature is an Option that checks the number and types of the arguments.
+// Unlike other Options, calls can be checked against it before running them (see TypeCheck).
+type Signature struct {
+	Types []interface{}
+	// Types are the types of the arguments, either a special (e.g. TypeCallable) or
+	// a value of the type (e.g. TypeNumber).
+
+	Prefix bool
+	// Prefix allows more arguments after Types.
+
+	Variadic bool
+	// Variadic allows any number of arguments, all of type Types[0].
+}
+
+func (s *Signature) Apply(_ IEnv, args []Evaler) ([]Evaler, error) {
+	switch {
+	case s.Variadic:
 		for i, arg := range args {
-			var argOk bool
-			if at, ok := argType.(special); ok {
-				argOk = at(arg)
-			} else {
-				argOk = reflect.TypeOf(argType).String() == reflect.TypeOf(arg).String()
-			}
-			if !argOk {
-				return nil, fmt.Errorf("wanted variadic %s, got %s (%d)", argType, StringSliceEvaler(args), i)
+			if !matches(s.Types[0], arg) {
+				return nil, fmt.Errorf("wanted variadic %s, got %s (%d)", typeName(s.Types[0]), StringSliceEvaler(args), i)
 			}
 		}
 		return args, nil
+	case s.Prefix && len(s.Types) > len(args):
+		return nil, fmt.Errorf("wanted %s+, got %s (length)", util.StringSliceInterface(s.Types), StringSliceEvaler(args))
+	case !s.Prefix && len(s.Types) != len(args):
+		if len(s.Types) == 0 {
+			return nil, fmt.Errorf("wanted 0, got %s (length)", StringSliceEvaler(args))
+		}
+		return nil, fmt.Errorf("wanted %s, got %s (length)", util.StringSliceInterface(s.Types), StringSliceEvaler(args))
 	}
+	for i, argType := range s.Types {
+		if !matches(argType, args[i]) {
+			return nil, fmt.Errorf("wanted %s, got %s (%d)", util.StringSliceInterface(s.Types), StringSliceEvaler(args), i)
+		}
+	}
+	return args, nil
+}
+
+// String returns s like (Number Callable), (String+) or (Any...).
+func (s *Signature) String() string {
+	if s.Variadic {
+		return "(" + typeName(s.Types[0]) + "...)"
+	}
+	names := make([]string, len(s.Types))
+	for i, t := range s.Types {
+		names[i] = typeName(t)
+	}
+	if s.Prefix {
+		return "(" + strings.Join(names, " ") + "+)"
+	}
+	return "(" + strings.Join(names, " ") + ")"
+}
+
+func OptionArgsPrefix(argTypes ...interface{}) Option {
+	return &Signature{Types: argTypes, Prefix: true}
+}
+
+var OptionNone Option = &Signature{}
+
+func OptionArgs(argTypes ...interface{}) Option {
+	return &Signature{Types: argTypes}
+}
+
+func OptionVariadic(argType interface{}) Option {
+	return &Signature{Types: []interface{}{argType}, Variadic: true}
 }
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// PolicyRG is a ResourcesGuard that allows or denies resources by rules, usually
+// read from a policy file (see ParsePolicy).
+// The last rule matching a resource decides; resources no rule matches are denied.
+type PolicyRG struct {
+	Rules []PolicyRule
+}
+
+var _ ResourcesGuard = new(PolicyRG)
+
+// PolicyRule allows or denies resources whose names and arguments match globs.
+type PolicyRule struct {
+	Allow bool
+	Name  string
+	Arg   string
+	// Arg is "*" for rules without an argument.
+
+	name, arg glob.Glob
+}
+
+func NewPolicyRule(allow bool, name, arg string) (PolicyRule, error) {
+	r := PolicyRule{Allow: allow, Name: name, Arg: arg}
+	var err error
+	r.name, err = glob.Compile(name)
+	if err != nil {
+		return PolicyRule{}, fmt.Errorf("resource %s: %w", name, err)
+	}
+	if isPathResource(name) && arg != "*" {
+		arg = filepath.ToSlash(filepath.Clean(arg))
+	}
+	r.arg, err = glob.Compile(arg)
+	if err != nil {
+		return PolicyRule{}, fmt.Errorf("argument %s: %w", arg, err)
+	}
+	return r, nil
+}
+
+// Matches returns whether r applies to resource.
+func (r PolicyRule) Matches(resource util.Resource) bool {
+	arg := resource.Arg
+	if isPathResource(resource.Name) {
+		// so that e.g. data/../secret does not match data/*
+		arg = filepath.ToSlash(filepath.Clean(arg))
+	}
+	return r.name.Match(resource.Name) && r.arg.Match(arg)
+}
+
+func (r PolicyRule) String() string {
+	action := "deny"
+	if r.Allow {
+		action = "allow"
+	}
+	return fmt.Sprintf("%s %s %s", action, r.Name, r.Arg)
+}
+
+// isPathResource returns whether the arguments of the resource name are file paths.
+func isPathResource(name string) bool { return strings.HasPrefix(name, "fs.") }
+
+func (p *PolicyRG) Allowed(resource util.Resource) bool {
+	allowed := false
+	for _, r := range p.Rules {
+		if r.Matches(resource) {
+			allowed = r.Allow
+		}
+	}
+	return allowed
+}
+
+// ParsePolicy parses a policy file.
+// Each line is a rule, a comment starting with # or blank:
+//
+//	allow fs.local data/*
+//	allow http https://example.com/*
+//	deny fs.local data/secret.txt
+//	allow io.*
+//
+// A rule is allow or deny, a glob of resource names (e.g. fs.local, http,
+// io.stdin, io.stdout, os.exit, os.time), and optionally a glob of the argument
+// (e.g. the path or URL), which matches any argument if omitted.
+// In globs, * matches any string (including /).
+// Arguments of fs.* resources are cleaned (see filepath.Clean) before matching.
+func ParsePolicy(filename string, r io.Reader) (*PolicyRG, error) {
+	p := &PolicyRG{Rules: make([]PolicyRule, 0)}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if i := strings.Index(text, "#"); i != -1 {
+			text = strings.TrimSpace(text[:i])
+		}
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: wanted allow|deny name [arg], got %s", filename, line, text)
+		}
+		var allow bool
+		switch fields[0] {
+		case "allow":
+			allow = true
+		case "deny":
+			allow = false
+		default:
+			return nil, fmt.Errorf("%s:%d: wanted allow or deny, got %s", filename, line, fields[0])
+		}
+		arg := "*"
+		if len(fields) == 3 {
+			arg = fields[2]
+		}
+		rule, err := NewPolicyRule(allow, fields[1], arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, line, err)
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadPolicy parses the policy file at path (see ParsePolicy).
+func LoadPolicy(path string) (*PolicyRG, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePolicy(path, f)
+}
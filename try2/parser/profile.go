@@ -0,0 +1,14 @@
+package parser
+
+import (
+	"gitlab.com/coalang/go-coa/try2/prof"
+)
+
+// callSite returns the call site of c for profiling.
+func callSite(c *Call) prof.Site {
+	site := prof.Site{Pos: c.Pos}
+	if a := c.Content.Content[0]; a.ID != nil {
+		site.Name = a.ID.Content
+	}
+	return site
+}
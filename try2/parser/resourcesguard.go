@@ -1,6 +1,11 @@
 package parser
 
-import "gitlab.com/coalang/go-coa/try2/util"
+import (
+	"fmt"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
 
 type ResourcesGuard interface {
 	Allowed(util.Resource) bool
@@ -17,3 +22,42 @@ type MapRG struct{ m map[util.Resource]bool }
 var _ ResourcesGuard = new(MapRG)
 
 func (m *MapRG) Allowed(resource util.Resource) bool { return m.m[resource] }
+
+// ResourceDeniedError is returned when a call uses a resource denied by the
+// ResourcesGuard of its Env or one of the outer Envs.
+type ResourceDeniedError struct {
+	Pos      lexer.Position
+	Resource util.Resource
+}
+
+func (e *ResourceDeniedError) Error() string {
+	if e.Resource.Arg == "" {
+		return fmt.Sprintf("%s: resource denied: %s", e.Pos, e.Resource.Name)
+	}
+	return fmt.Sprintf("%s: resource denied: %s for %q", e.Pos, e.Resource.Name, e.Resource.Arg)
+}
+
+// CallResources returns the resources used by calling a callable using defs with
+// args, with the argument each ResourceDef.Arg refers to.
+func CallResources(defs []util.ResourceDef, args []Evaler) ([]util.Resource, error) {
+	rs := make([]util.Resource, len(defs))
+	for i, def := range defs {
+		rs[i].Name = def.Name
+		if def.Arg == -1 {
+			continue
+		}
+		if def.Arg < 0 || def.Arg >= len(args) {
+			return nil, fmt.Errorf("resource %s: argument %d missing", def.Name, def.Arg)
+		}
+		rs[i].Arg = util.ToString(args[def.Arg])
+	}
+	return rs, nil
+}
+
+// GuardResources returns a *ResourceDeniedError for the first of rs that env denies.
+func GuardResources(env IEnv, pos lexer.Position, rs []util.Resource) error {
+	if bad := env.BadResources(rs); len(bad) != 0 {
+		return &ResourceDeniedError{Pos: pos, Resource: rs[bad[0]]}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+// Stdio is the standard streams used by natives (e.g. @io_out and @io_in) of an
+// Env or VM.
+// Nil fields are replaced by os.Stdin, os.Stdout and os.Stderr.
+// In must not be changed after it is first read from.
+type Stdio struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+
+	lock sync.Mutex
+	in   *bufio.Reader
+	// in buffers In for all reads, so that input buffered by a read is not lost.
+}
+
+// osStdio is used by Envs and VMs without a Stdio.
+var osStdio = new(Stdio)
+
+// Reader returns a buffered reader of the In of s.
+// The reader is shared by all calls, so reads must not be concurrent (natives
+// lock the io.stdin resource).
+// s may be nil for os.Stdin.
+func (s *Stdio) Reader() *bufio.Reader {
+	if s == nil {
+		s = osStdio
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.in == nil {
+		var in io.Reader = os.Stdin
+		if s.In != nil {
+			in = s.In
+		}
+		if b, ok := in.(*bufio.Reader); ok {
+			s.in = b
+		} else {
+			s.in = bufio.NewReader(in)
+		}
+	}
+	return s.in
+}
+
+// Writer returns the Out of s, or os.Stdout if s or Out is nil.
+func (s *Stdio) Writer() io.Writer {
+	if s == nil || s.Out == nil {
+		return os.Stdout
+	}
+	return s.Out
+}
+
+// ErrWriter returns the Err of s, or os.Stderr if s or Err is nil.
+func (s *Stdio) ErrWriter() io.Writer {
+	if s == nil || s.Err == nil {
+		return os.Stderr
+	}
+	return s.Err
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Tester runs the tests defined by (@test name block), e.g. to filter and report
+// them (see Host.Tester).
+type Tester interface {
+	// Test runs the test named name, whose block is at pos, by calling run, or
+	// skips it. It returns whether the test passed or was skipped.
+	// Test may be called concurrently.
+	Test(name string, pos lexer.Position, run func() error) bool
+}
+
+// runTest is @test, which calls a block in an Env that only sees builtins.
+// Without a Tester, the error of a failed test is returned.
+func runTest(env IEnv, args []Evaler) (Evaler, error) {
+	name := args[0].(BecomesString).BecomeString()
+	block := args[1].(Callable)
+	pos := GetPos(block)
+	run := func() error {
+		_, err := block.Call(env.InheritLone(pos), nil)
+		return err
+	}
+	tester := hostOf(env).Tester
+	if tester == nil {
+		err := run()
+		if err != nil {
+			return nil, fmt.Errorf("test %s failed: %w", name, err)
+		}
+		return NewBool(true), nil
+	}
+	return NewBool(tester.Test(name, pos, run)), nil
+}
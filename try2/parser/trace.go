@@ -0,0 +1,15 @@
+package parser
+
+import (
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// resourcesNames returns the names of the resources rs with their arguments
+// args, for tracing.
+func resourcesNames(rs []util.ResourceDef, args []string) []string {
+	re := make([]string, len(rs))
+	for i, r := range rs {
+		re[i] = r.Name + " " + args[i]
+	}
+	return re
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// Kinds of errors caught by @try (see ErrorValue.Kind).
+const (
+	KindError     = "error"
+	KindAssert    = "assert"
+	KindUndefined = "undefined"
+	KindNative    = "native"
+	// KindNative is any other error, e.g. of a native given the wrong arguments.
+)
+
+// RaisedError is an error raised by the program itself, with (@error) or a
+// failed (@assert).
+type RaisedError struct {
+	Kind    string
+	Message string
+}
+
+func (e *RaisedError) Error() string {
+	if e.Kind == KindAssert {
+		return "failed assertion: " + e.Message
+	}
+	return "error: " + e.Message
+}
+
+// ErrorValue is an error caught by @try, given to the handler.
+// It is a MapLike with the keys "message", "kind", "pos" and "frames".
+type ErrorValue struct {
+	util.NoCopy
+	Kind    string
+	Message string
+	Pos     lexer.Position
+	// Pos is the position of the innermost frame, or of the @try if there are no
+	// frames.
+	Frames []errs.ReportFrame
+	// Frames are the calls the error happened in, innermost first (see
+	// errs.NewReport).
+	Err error
+}
+
+var _ Evaler = new(ErrorValue)
+var _ MapLike = new(ErrorValue)
+
+// NewErrorValue returns the ErrorValue of err, which happened in the call at pos.
+func NewErrorValue(err error, pos lexer.Position) *ErrorValue {
+	report := errs.NewReport(err)
+	e := &ErrorValue{
+		Kind:    errorKind(err),
+		Message: report.Message,
+		Pos:     pos,
+		Frames:  report.Frames,
+		Err:     err,
+	}
+	var raised *RaisedError
+	if errors.As(err, &raised) {
+		e.Message = raised.Message
+	}
+	if len(e.Frames) != 0 {
+		e.Pos = e.Frames[0].Pos()
+	}
+	return e
+}
+
+func errorKind(err error) string {
+	var raised *RaisedError
+	switch {
+	case errors.As(err, &raised):
+		return raised.Kind
+	case errors.Is(err, ErrUndefined):
+		return KindUndefined
+	default:
+		return KindNative
+	}
+}
+
+func (e *ErrorValue) Info(_ IEnv) util.Info                  { return util.InfoPure }
+func (e *ErrorValue) Eval(_ IEnv) (result Evaler, err error) { return e, nil }
+func (e *ErrorValue) String() string                         { return e.Inspect() }
+func (e *ErrorValue) Inspect() string {
+	return fmt.Sprintf("%s error at %s: %s", e.Kind, e.Pos, e.Message)
+}
+func (e *ErrorValue) IDUses() []string     { return nil }
+func (e *ErrorValue) IDSets() []string     { return nil }
+func (e *ErrorValue) BecomeString() string { return e.Message }
+
+func (e *ErrorValue) Get(key string) (Evaler, bool, error) {
+	switch key {
+	case "message":
+		return NewString(e.Message), true, nil
+	case "kind":
+		return NewString(e.Kind), true, nil
+	case "pos":
+		return NewString(e.Pos.String()), true, nil
+	case "frames":
+		frames := make([]Node, len(e.Frames))
+		for i, f := range e.Frames {
+			frames[i] = toNode(&Map{Pos: f.Pos(), Content: map[string]Evaler{
+				"pos":  NewString(f.Pos().String()),
+				"call": NewString(f.Call),
+			}})
+		}
+		return &List{Content: Nodes{Content: frames}}, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+func (e *ErrorValue) Set(key string, _ Evaler) error {
+	return fmt.Errorf("cannot set %s of error: errors are read-only", key)
+}
+
+func (e *ErrorValue) Keys() (keys []string) {
+	return []string{"frames", "kind", "message", "pos"}
+}
+
+// catchable returns whether @try catches err. Control flow (e.g. @return),
+// cancellation, exceeded limits and quitting the Debugger are not errors of the
+// program, so they are not caught.
+func catchable(err error) bool {
+	var canceled *CanceledError
+	var limit *LimitError
+	return !errors.Is(err, ErrInternal) &&
+		!errors.Is(err, &ErrReturn{}) &&
+		!errors.Is(err, ErrQuit) &&
+		!errors.As(err, &canceled) &&
+		!errors.As(err, &limit)
+}
+
+// runTry is @try, which calls body, and calls handler with the error as an
+// ErrorValue if body fails with a catchable error.
+func runTry(env IEnv, args []Evaler) (Evaler, error) {
+	body := args[0].(Callable)
+	handler := args[1].(Callable)
+	result, err := body.Call(env, nil)
+	if err == nil || !catchable(err) {
+		return result, err
+	}
+	return handler.Call(env, []Evaler{NewErrorValue(err, GetPos(body))})
+}
+
+// runErrorIs is @error_is, which returns whether an ErrorValue is of a kind.
+func runErrorIs(env IEnv, args []Evaler) (Evaler, error) {
+	e := args[0].(*ErrorValue)
+	return NewBool(e.Kind == args[1].(BecomesString).BecomeString()), nil
+}
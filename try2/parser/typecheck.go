@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/errs"
+)
+
+// TypeError is a call whose arguments do not match the Signature of the called native.
+type TypeError struct {
+	Pos     lexer.Position
+	Callee  string
+	Message string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Pos, e.Callee, e.Message)
+}
+
+func (e *TypeError) Position() lexer.Position { return e.Pos }
+
+// TypeCheck checks the calls in nodes against the Signatures of the natives they
+// call without running them, and returns all mismatches as errs.Errors of *TypeError.
+//
+// Types are known for literals, builtins and variables defined to them with @def.
+// Arguments of unknown types (e.g. results of calls or arguments of blocks) are
+// assumed to match.
+func TypeCheck(nodes *Nodes) error {
+	c := &typeChecker{scope: newTypeScope(nil)}
+	for name, evaler := range newBase() {
+		c.scope.types[name] = evaler
+	}
+	c.nodes(nodes.Content)
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
+
+type typeScope struct {
+	outer *typeScope
+	types map[string]Evaler
+	// types are values of the types of variables, or nil if unknown.
+}
+
+func newTypeScope(outer *typeScope) *typeScope {
+	return &typeScope{outer: outer, types: map[string]Evaler{}}
+}
+
+// lookup returns the scope defining name, or nil if none do.
+func (s *typeScope) lookup(name string) *typeScope {
+	for ; s != nil; s = s.outer {
+		if _, ok := s.types[name]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+type typeChecker struct {
+	scope *typeScope
+	errs  errs.Errors
+}
+
+func (c *typeChecker) nodes(nodes []Node) {
+	for i := range nodes {
+		c.node(&nodes[i])
+	}
+}
+
+// node checks n, and returns a value of the type n evaluates to, or nil if unknown.
+func (c *typeChecker) node(n *Node) Evaler {
+	switch {
+	case n.Number != nil:
+		return n.Number
+	case n.String_ != nil:
+		return n.String_
+	case n.Rune != nil:
+		return n.Rune
+	case n.ID != nil:
+		if s := c.scope.lookup(n.ID.Content); s != nil {
+			return s.types[n.ID.Content]
+		}
+		return nil
+	case n.List != nil:
+		c.nodes(n.List.Content.Content)
+		if n.List.isMap() {
+			return TypeMap
+		}
+		return n.List
+	case n.Block != nil:
+		c.scope = newTypeScope(c.scope)
+		c.nodes(n.Block.Content.Content)
+		c.scope = c.scope.outer
+		return n.Block
+	case n.Call != nil:
+		return c.call(n.Call)
+	default:
+		return nil
+	}
+}
+
+func (c *typeChecker) call(call *Call) Evaler {
+	content := call.Content.Content
+	if len(content) == 0 {
+		return nil
+	}
+	callee := c.node(&content[0])
+	types := make([]Evaler, len(content)-1)
+	for i := range types {
+		types[i] = c.node(&content[i+1])
+	}
+	native, ok := callee.(*Native)
+	if !ok {
+		if callee != nil && !matches(TypeCallable, callee) {
+			c.error(call.Pos, content[0], fmt.Sprintf("cannot call %s", typeName(callee)))
+		}
+		return nil
+	}
+	if s := native.Signature(); s != nil {
+		args := types
+		if native.special {
+			// special natives get the nodes themselves
+			args = make([]Evaler, len(types))
+			for i := range args {
+				args[i] = content[i+1].Select()
+			}
+		}
+		c.signature(call, s, args)
+	}
+	if content[0].ID != nil && len(content) == 3 && content[1].ID != nil {
+		c.bind(content[0].ID.Content, content[1].ID.Content, types[1])
+	}
+	return nil
+}
+
+// bind records the type of the variable defined or modified by (@def name value)
+// or (@mod name value).
+func (c *typeChecker) bind(callee, name string, t Evaler) {
+	switch callee {
+	case "@def":
+		c.scope.types[name] = t
+	case "@mod":
+		s := c.scope.lookup(name)
+		if s == nil {
+			return
+		}
+		if old := s.types[name]; old == nil || t == nil || typeName(old) != typeName(t) {
+			// the type depends on which runs last
+			s.types[name] = nil
+		}
+	}
+}
+
+func (c *typeChecker) signature(call *Call, s *Signature, args []Evaler) {
+	callee := call.Content.Content[0]
+	switch {
+	case s.Variadic:
+	case s.Prefix && len(args) < len(s.Types):
+		c.error(call.Pos, callee, fmt.Sprintf("wanted %s, got %d argument(s)", s, len(args)))
+		return
+	case !s.Prefix && len(args) != len(s.Types):
+		c.error(call.Pos, callee, fmt.Sprintf("wanted %s, got %d argument(s)", s, len(args)))
+		return
+	}
+	for i, arg := range args {
+		var t interface{}
+		switch {
+		case s.Variadic:
+			t = s.Types[0]
+		case i < len(s.Types):
+			t = s.Types[i]
+		default:
+			return
+		}
+		if arg == nil || matches(t, arg) {
+			continue
+		}
+		c.error(call.Content.Content[i+1].Pos, callee, fmt.Sprintf("argument %d: wanted %s, got %s", i+1, typeName(t), typeName(arg)))
+	}
+}
+
+func (c *typeChecker) error(pos lexer.Position, callee Node, message string) {
+	c.errs = append(c.errs, &TypeError{Pos: pos, Callee: callee.String(), Message: message})
+}
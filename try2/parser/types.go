@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -63,6 +64,11 @@ type IEnv interface {
 	AllowParallel2() bool
 	Debug2() bool
 	Pos2() lexer.Position
+	Context() context.Context
+	Limits2() *Limits
+	Deterministic2() *Deterministic
+	Stdio2() *Stdio
+	StackLen() int
 
 	Printf(format string, v ...interface{})
 
@@ -0,0 +1,181 @@
+package prof
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+)
+
+// WritePprof writes p to w as a gzipped pprof profile (profile.proto), so that
+// `go tool pprof` can show it.
+// Each call site is a location, in a function named after the callee.
+// Samples have two values: calls/count and time/nanoseconds (exclusive).
+func (p *Profile) WritePprof(w io.Writer) error {
+	p.lock.Lock()
+	data := p.encode(time.Since(p.start))
+	p.lock.Unlock()
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Field numbers of profile.proto.
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// encode returns p in the format of profile.proto.
+// p must be locked.
+func (p *Profile) encode(duration time.Duration) []byte {
+	strs := &stringTable{ids: map[string]int64{"": 0}, strs: []string{""}}
+	b := new(protoBuffer)
+
+	valueType := func(field int, typ, unit string) {
+		vt := new(protoBuffer)
+		vt.int(valueTypeType, strs.id(typ))
+		vt.int(valueTypeUnit, strs.id(unit))
+		b.message(field, vt)
+	}
+	valueType(profileSampleType, "calls", "count")
+	valueType(profileSampleType, "time", "nanoseconds")
+
+	keys := make([]string, 0, len(p.stacks))
+	for key := range p.stacks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		st := p.stacks[key]
+		s := new(protoBuffer)
+		locs := make([]uint64, len(st.ids))
+		for i, id := range st.ids {
+			locs[i] = uint64(id + 1)
+		}
+		s.packed(sampleLocationID, locs)
+		s.packed(sampleValue, []uint64{uint64(st.calls), uint64(st.exclusive)})
+		b.message(profileSample, s)
+	}
+
+	// functions are the callees in each file
+	type function struct{ name, filename string }
+	functions := map[function]uint64{}
+	for i, site := range p.sites {
+		fn := function{site.name(), site.Pos.Filename}
+		fid, ok := functions[fn]
+		if !ok {
+			fid = uint64(len(functions) + 1)
+			functions[fn] = fid
+			f := new(protoBuffer)
+			f.uint(functionID, fid)
+			f.int(functionName, strs.id(fn.name))
+			f.int(functionSystemName, strs.id(fn.name))
+			f.int(functionFilename, strs.id(fn.filename))
+			f.int(functionStartLine, int64(site.Pos.Line))
+			b.message(profileFunction, f)
+		}
+		line := new(protoBuffer)
+		line.uint(lineFunctionID, fid)
+		line.int(lineLine, int64(site.Pos.Line))
+		l := new(protoBuffer)
+		l.uint(locationID, uint64(i+1))
+		l.message(locationLine, line)
+		b.message(profileLocation, l)
+	}
+
+	b.int(profileTimeNanos, p.start.UnixNano())
+	b.int(profileDurationNanos, int64(duration))
+	valueType(profilePeriodType, "time", "nanoseconds")
+	b.int(profilePeriod, 1)
+	for _, s := range strs.strs {
+		b.string(profileStringTable, s)
+	}
+	return b.buf
+}
+
+// stringTable is the string_table of a profile, whose first string is empty.
+type stringTable struct {
+	ids  map[string]int64
+	strs []string
+}
+
+func (t *stringTable) id(s string) int64 {
+	id, ok := t.ids[s]
+	if !ok {
+		id = int64(len(t.strs))
+		t.ids[s] = id
+		t.strs = append(t.strs, s)
+	}
+	return id
+}
+
+// protoBuffer encodes protocol buffer messages.
+type protoBuffer struct{ buf []byte }
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protoBuffer) varint(x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	b.buf = append(b.buf, buf[:n]...)
+}
+
+func (b *protoBuffer) key(field, wire int) { b.varint(uint64(field)<<3 | uint64(wire)) }
+
+func (b *protoBuffer) uint(field int, x uint64) {
+	b.key(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int(field int, x int64) { b.uint(field, uint64(x)) }
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(data)))
+	b.buf = append(b.buf, data...)
+}
+
+func (b *protoBuffer) string(field int, s string) { b.bytes(field, []byte(s)) }
+
+func (b *protoBuffer) message(field int, m *protoBuffer) { b.bytes(field, m.buf) }
+
+func (b *protoBuffer) packed(field int, xs []uint64) {
+	p := new(protoBuffer)
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.bytes(field, p.buf)
+}
@@ -0,0 +1,166 @@
+// Package prof profiles the calls of Coa code.
+//
+// Engines call Profile.Enter before each call and Frame.Exit after it, and the
+// Profile keeps the number of calls and the time spent per call site, and per
+// stack of call sites for WritePprof:
+//
+//	f := p.Enter(parent, prof.Site{Pos: pos, Name: "fib"})
+//	defer f.Exit()
+//
+// Times are wall times, so the time of calls evaluated in parallel may add up to
+// more than the time of their caller.
+package prof
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Site is a call site.
+type Site struct {
+	Pos  lexer.Position
+	Name string
+	// Name is the name of the callee (e.g. fib or @add), or empty if the callee
+	// is not a variable.
+}
+
+func (s Site) String() string { return s.Pos.String() + " " + s.name() }
+
+func (s Site) name() string {
+	if s.Name == "" {
+		return "(anonymous)"
+	}
+	return s.Name
+}
+
+// SiteStats is the calls of a Site.
+type SiteStats struct {
+	Site
+	Calls     int64
+	Inclusive time.Duration
+	// Inclusive is the time spent in calls, including their callees.
+	// Recursive calls are only counted once.
+	Exclusive time.Duration
+	// Exclusive is the time spent in calls, excluding their callees.
+}
+
+// Profile is the calls made while evaluating.
+// A nil *Profile profiles nothing.
+type Profile struct {
+	start time.Time
+
+	lock  sync.Mutex
+	ids   map[Site]int
+	sites []*SiteStats
+	// sites are indexed by the IDs in ids.
+	stacks map[string]*stack
+}
+
+// stack is the calls with the same stack of sites.
+type stack struct {
+	ids []int
+	// ids are the IDs of the sites, innermost first.
+	calls     int64
+	exclusive time.Duration
+}
+
+// New returns a Profile starting now.
+func New() *Profile {
+	return &Profile{
+		start:  time.Now(),
+		ids:    map[Site]int{},
+		stacks: map[string]*stack{},
+	}
+}
+
+// Frame is a call being profiled.
+// A nil *Frame is not profiled.
+type Frame struct {
+	p        *Profile
+	parent   *Frame
+	id       int
+	start    time.Time
+	children int64
+	// children is the time in nanoseconds spent in callees, added atomically as
+	// callees may run in parallel.
+}
+
+// Enter returns a Frame for a call at site made by parent, which is nil for
+// calls made outside of calls.
+func (p *Profile) Enter(parent *Frame, site Site) *Frame {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	id, ok := p.ids[site]
+	if !ok {
+		id = len(p.sites)
+		p.ids[site] = id
+		p.sites = append(p.sites, &SiteStats{Site: site})
+	}
+	p.lock.Unlock()
+	return &Frame{p: p, parent: parent, id: id, start: time.Now()}
+}
+
+// Exit records the call of f as done.
+func (f *Frame) Exit() {
+	if f == nil {
+		return
+	}
+	d := time.Since(f.start)
+	if f.parent != nil {
+		atomic.AddInt64(&f.parent.children, int64(d))
+	}
+	exclusive := d - time.Duration(atomic.LoadInt64(&f.children))
+	if exclusive < 0 {
+		// callees ran in parallel
+		exclusive = 0
+	}
+
+	ids := make([]int, 0, 8)
+	recursive := false
+	key := new(strings.Builder)
+	for g := f; g != nil; g = g.parent {
+		if g != f && g.id == f.id {
+			recursive = true
+		}
+		ids = append(ids, g.id)
+		key.WriteString(strconv.Itoa(g.id))
+		key.WriteByte(' ')
+	}
+
+	p := f.p
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s := p.sites[f.id]
+	s.Calls++
+	s.Exclusive += exclusive
+	if !recursive {
+		s.Inclusive += d
+	}
+	st, ok := p.stacks[key.String()]
+	if !ok {
+		st = &stack{ids: ids}
+		p.stacks[key.String()] = st
+	}
+	st.calls++
+	st.exclusive += exclusive
+}
+
+// Sites returns the stats of all sites called, sorted by exclusive time.
+func (p *Profile) Sites() []SiteStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	re := make([]SiteStats, len(p.sites))
+	for i, s := range p.sites {
+		re[i] = *s
+	}
+	sort.SliceStable(re, func(i, j int) bool { return re[i].Exclusive > re[j].Exclusive })
+	return re
+}
@@ -0,0 +1,185 @@
+// Package repl implements an interactive read-eval-print loop for Coa.
+//
+// Inputs continue on the next line while parens, brackets or braces are open
+// (see Depth), and lines starting with : are commands (see Help).
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// Help is the help of the commands, shown by :help.
+const Help = `:dump          show the variables defined so far
+:peek nodes... show uses, sets and resources of nodes
+:load path     evaluate the file at path
+:help          show this
+Input continues on the next line while parens, brackets or braces are open.`
+
+// REPL reads, evaluates and prints Coa code interactively, keeping one Env.
+type REPL struct {
+	Env *parser.Env
+	Out io.Writer
+	// Out is written to by natives, and gets prompts, results and errors.
+
+	n int
+	// n is the number of inputs so far, used for positions.
+}
+
+func New(env *parser.Env, out io.Writer) *REPL {
+	return &REPL{Env: env, Out: out}
+}
+
+// Run reads inputs from in until EOF.
+// @io_in reads from in too, sharing its buffer with the prompt.
+func (r *REPL) Run(in io.Reader) error {
+	stdio := &parser.Stdio{In: in, Out: r.Out}
+	r.Env.Stdio = stdio
+	reader := stdio.Reader()
+	var input strings.Builder
+	for {
+		if input.Len() == 0 {
+			fmt.Fprint(r.Out, "coa> ")
+		} else {
+			fmt.Fprint(r.Out, "...> ")
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(r.Out)
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if input.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			r.command(strings.TrimSpace(line))
+			continue
+		}
+		input.WriteString(line)
+		input.WriteString("\n")
+		if Depth(input.String()) > 0 {
+			continue
+		}
+		r.eval(input.String())
+		input.Reset()
+	}
+}
+
+func (r *REPL) command(line string) {
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i != -1 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	switch name {
+	case ":dump":
+		fmt.Fprintln(r.Out, r.Env.Dump())
+	case ":peek":
+		root, err := r.parse(arg)
+		if err != nil {
+			r.error(err)
+			return
+		}
+		parser.Bifrost.Peek(r.Env, root.Select())
+	case ":load":
+		if arg == "" {
+			r.error(errors.New("usage: :load path"))
+			return
+		}
+		result, err := r.Env.LoadPath(arg)
+		if err != nil {
+			r.error(err)
+			return
+		}
+		r.print(result)
+	case ":help":
+		fmt.Fprintln(r.Out, Help)
+	default:
+		r.error(fmt.Errorf("unknown command %s (see :help)", name))
+	}
+}
+
+func (r *REPL) parse(src string) (*parser.Nodes, error) {
+	r.n++
+	root := &parser.Nodes{}
+	err := parser.Parser.ParseString(fmt.Sprintf("repl#%d", r.n), src, root)
+	if err != nil {
+		if err, ok := err.(participle.UnexpectedTokenError); ok {
+			return nil, fmt.Errorf("%s: expected %s, got %s", err.Position(), err.Expected, err.Unexpected)
+		}
+		return nil, err
+	}
+	return root, nil
+}
+
+func (r *REPL) eval(src string) {
+	root, err := r.parse(src)
+	if err != nil {
+		r.error(err)
+		return
+	}
+	// nodes are evaluated one by one, as variables defined by an input are
+	// usually used by later ones (root.Eval would reject them as unused)
+	var result parser.Evaler
+	for _, n := range root.Select() {
+		result, err = parser.Eval(n, r.Env)
+		if err != nil {
+			r.error(err)
+			return
+		}
+	}
+	r.print(result)
+}
+
+func (r *REPL) print(result parser.Evaler) {
+	if result == nil {
+		return
+	}
+	fmt.Fprintln(r.Out, util.ToInspect(result))
+}
+
+func (r *REPL) error(err error) {
+	fmt.Fprintf(r.Out, "error: %s\n", err)
+}
+
+// Depth returns the number of parens, brackets and braces left open in src.
+// An unterminated string or rune counts as open, and comments are skipped.
+func Depth(src string) int {
+	depth := 0
+	var quote rune
+	escaped := false
+	comment := false
+	for _, c := range src {
+		switch {
+		case comment:
+			comment = c != '\n'
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			comment = true
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		}
+	}
+	if quote != 0 {
+		depth++
+	}
+	return depth
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/coalang/go-coa/try2/coa"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+func TestRuntime(t *testing.T) {
+	r := coa.New()
+	stdout := new(bytes.Buffer)
+	r.Stdout = stdout
+	r.Stdin = strings.NewReader("line\nline 2\n")
+	err := r.RegisterNative("@greet", func(args []interface{}) (interface{}, error) {
+		return "hello, " + args[0].(string), nil
+	}, coa.Args(coa.String))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Set("name", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Set("xs", []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	result, err := r.Eval(ctx, `(@io_outln (@greet name))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != float64(len("hello, world\n")) {
+		t.Errorf("result: want %d, got %v", len("hello, world\n"), result)
+	}
+	if stdout.String() != "hello, world\n" {
+		t.Errorf("stdout: want hello, world, got %q", stdout)
+	}
+
+	_, err = r.Eval(ctx, `(@def doubled (@map xs {(@mul $1 2)}))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doubled, ok := r.Get("doubled")
+	if !ok {
+		t.Fatal("doubled not defined")
+	}
+	if want := []interface{}{2.0, 4.0, 6.0}; !reflect.DeepEqual(doubled, want) {
+		t.Errorf("doubled: want %#v, got %#v", want, doubled)
+	}
+
+	result, err = r.Eval(ctx, `(@io_in '\n')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "line" {
+		t.Errorf("stdin: want line, got %v", result)
+	}
+	// input buffered by the last Eval is not lost
+	result, err = r.Eval(ctx, `(@io_in '\n')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "line 2" {
+		t.Errorf("stdin: want line 2, got %v", result)
+	}
+
+	_, err = r.Eval(ctx, `(@greet 1)`)
+	if err == nil || !strings.Contains(err.Error(), "wanted") {
+		t.Errorf("want type error, got %v", err)
+	}
+}
+
+func TestRuntimeNativeNil(t *testing.T) {
+	r := coa.New()
+	called := false
+	err := r.RegisterNative("@touch", func(args []interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Eval(context.Background(), `(@touch)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("@touch not called")
+	}
+	if result != nil {
+		t.Errorf("want nil, got %#v", result)
+	}
+}
+
+func TestRuntimePanic(t *testing.T) {
+	for _, src := range []string{
+		`(@concat [1 2] "a")`,
+		// in strands, which run in their own goroutines
+		"(@def f {\n(@def a (@concat [1 2] \"a\"))\n(@def b (@concat [3 4] \"b\"))\n(@concat a b)\n})\n(f)",
+	} {
+		_, err := coa.New().Eval(context.Background(), src)
+		var panicErr *parser.PanicError
+		if !errors.As(err, &panicErr) {
+			t.Errorf("%s: want panic error, got %v", src, err)
+		}
+	}
+}
+
+func TestRuntimeGuard(t *testing.T) {
+	r := coa.New()
+	r.Stdout = new(bytes.Buffer)
+	r.Guard = parser.PureRG{}
+	_, err := r.Eval(context.Background(), `(@io_out "x")`)
+	var denied *parser.ResourceDeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("want resource denied error, got %v", err)
+	}
+	if denied.Resource != (util.Resource{Name: "io.stdout"}) {
+		t.Errorf("want io.stdout denied, got %s", denied.Resource)
+	}
+}
+
+func TestRuntimeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := coa.New().Eval(ctx, `1`)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = coa.New().Eval(ctx, `(@while @true {1})`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	values := []interface{}{1.5, 1 + 2i, "a", true, 'r', []interface{}{1.0, "b"}, map[string]interface{}{"k": []interface{}{false}}}
+	for _, value := range values {
+		evaler, err := coa.FromGo(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := coa.ToGo(evaler); !reflect.DeepEqual(got, value) {
+			t.Errorf("want %#v, got %#v", value, got)
+		}
+	}
+	if _, err := coa.FromGo(struct{}{}); err == nil {
+		t.Error("want error converting struct{}{}")
+	}
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+func TestContextCanceled(t *testing.T) {
+	cases := []struct {
+		name, src string
+	}{
+		{"while", `(@while @true {1})`},
+		{"for", "(@def i 0)\n(@def xs (@for (@mod i 0) @true (@mod i (@add i 1)) {i}))\n(@eq xs [])"},
+		{"sleep", `(@time_sleep 60)`},
+		{"strands", "(@def a (@while @true {1}))\n(@def b (@while @true {2}))\n(@add a b)"},
+	}
+	for _, c := range cases {
+		tc := testCase(t, c.name, c.src)
+		for _, cfg := range TestCaseConfigs {
+			t.Run(c.name+" "+cfg.String(), func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				cfg.Ctx = ctx
+				done := make(chan error)
+				go func() {
+					_, err := tc.Eval(cfg)
+					done <- err
+				}()
+				select {
+				case err := <-done:
+					var canceled *parser.CanceledError
+					if !errors.As(err, &canceled) {
+						t.Fatalf("want *parser.CanceledError, got %v", err)
+					}
+					if !errors.Is(err, context.DeadlineExceeded) {
+						t.Errorf("want context.DeadlineExceeded, got %v", err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("not canceled")
+				}
+			})
+		}
+	}
+}
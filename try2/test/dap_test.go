@@ -0,0 +1,184 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/dap"
+)
+
+// dapClient drives a dap.Server over pipes.
+type dapClient struct {
+	t        *testing.T
+	w        io.WriteCloser
+	messages chan *dap.Message
+	seq      int
+
+	events []*dap.Message
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	s := dap.NewServer()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		err := s.Serve(inR, outW)
+		if err != nil {
+			t.Error(err)
+		}
+		_ = outW.Close()
+	}()
+	c := &dapClient{t: t, w: inW, messages: make(chan *dap.Message, 16)}
+	go func() {
+		// read messages as they come, as writes to pipes block until read
+		defer close(c.messages)
+		r := bufio.NewReader(outR)
+		for {
+			m, err := dap.ReadMessage(r)
+			if err != nil {
+				return
+			}
+			c.messages <- m
+		}
+	}()
+	t.Cleanup(func() {
+		_ = c.w.Close()
+		for range c.messages {
+		}
+	})
+	return c
+}
+
+// request sends a request, and decodes the body of its response into body.
+// Events received meanwhile are kept.
+func (c *dapClient) request(command string, arguments interface{}, body interface{}) {
+	c.t.Helper()
+	c.seq++
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	err = dap.WriteMessage(c.w, &dap.Message{Seq: c.seq, Type: "request", Command: command, Arguments: data})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for m := range c.messages {
+		if m.Type == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m.RequestSeq != c.seq {
+			c.t.Fatalf("response for %d, want %d", m.RequestSeq, c.seq)
+		}
+		if !m.Success {
+			c.t.Fatalf("%s: %s", command, m.Message)
+		}
+		if body != nil {
+			err := json.Unmarshal(m.Body, body)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+	c.t.Fatalf("%s: no response", command)
+}
+
+// wait returns the next event named name, skipping others.
+func (c *dapClient) wait(name string) *dap.Message {
+	c.t.Helper()
+	for len(c.events) != 0 {
+		m := c.events[0]
+		c.events = c.events[1:]
+		if m.Event == name {
+			return m
+		}
+	}
+	for m := range c.messages {
+		if m.Type == "event" && m.Event == name {
+			return m
+		}
+	}
+	c.t.Fatalf("no %s event", name)
+	return nil
+}
+
+func TestDAP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debug.coa")
+	err := os.WriteFile(path, []byte(debugSrc), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []string{"vm", "interp"} {
+		t.Run(engine, func(t *testing.T) {
+			c := newDAPClient(t)
+			c.request("initialize", map[string]interface{}{"adapterID": "coa"}, nil)
+			c.wait("initialized")
+			c.request("launch", dap.LaunchArguments{Program: path, Engine: engine}, nil)
+			var breakpoints dap.SetBreakpointsResponseBody
+			c.request("setBreakpoints", dap.SetBreakpointsArguments{
+				Source:      dap.Source{Path: path},
+				Breakpoints: []dap.SourceBreakpoint{{Line: 2}},
+			}, &breakpoints)
+			if len(breakpoints.Breakpoints) != 1 || !breakpoints.Breakpoints[0].Verified {
+				t.Fatalf("unexpected breakpoints %v", breakpoints.Breakpoints)
+			}
+			c.request("configurationDone", nil, nil)
+
+			var stopped dap.StoppedEventBody
+			err := json.Unmarshal(c.wait("stopped").Body, &stopped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stopped.Reason != "breakpoint" {
+				t.Fatalf("want reason breakpoint, got %s", stopped.Reason)
+			}
+			var trace dap.StackTraceResponseBody
+			c.request("stackTrace", dap.StackTraceArguments{ThreadID: 1}, &trace)
+			if len(trace.StackFrames) < 2 {
+				t.Fatalf("want at least 2 frames, got %v", trace.StackFrames)
+			}
+			if f := trace.StackFrames[0]; f.Line != 2 || f.Source.Path != path {
+				t.Fatalf("unexpected top frame %v", f)
+			}
+
+			var scopes dap.ScopesResponseBody
+			c.request("scopes", dap.ScopesArguments{FrameID: trace.StackFrames[0].ID}, &scopes)
+			found := false
+			for _, s := range scopes.Scopes {
+				var vars dap.VariablesResponseBody
+				c.request("variables", dap.VariablesArguments{VariablesReference: s.VariablesReference}, &vars)
+				for _, v := range vars.Variables {
+					found = found || v.Name == "$0" && v.Value == "3"
+				}
+			}
+			if !found {
+				t.Errorf("$0 = 3 not found in scopes %v", scopes.Scopes)
+			}
+
+			var eval dap.EvaluateResponseBody
+			c.request("evaluate", dap.EvaluateArguments{Expression: "(@mul $0 2)", FrameID: trace.StackFrames[0].ID}, &eval)
+			if eval.Result != "6" {
+				t.Errorf("evaluate: want 6, got %s", eval.Result)
+			}
+
+			c.request("continue", nil, nil)
+			c.wait("stopped")
+			c.request("continue", nil, nil)
+			var exited dap.ExitedEventBody
+			err = json.Unmarshal(c.wait("exited").Body, &exited)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exited.ExitCode != 0 {
+				t.Fatalf("want exit code 0, got %d", exited.ExitCode)
+			}
+			c.wait("terminated")
+			c.request("disconnect", nil, nil)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/vm"
+)
+
+const debugSrc = `(@def sq {
+	(@def x (@mul $0 $0))
+	(@add x 1)
+})
+(@def a (sq 3))
+(@def b (sq a))
+(@add a b)`
+
+func TestDebugger(t *testing.T) {
+	tc := testCase(t, "debug", debugSrc)
+	cases := []struct {
+		name    string
+		breaks  []int
+		pause   bool
+		actions []vm.Action
+		// actions are returned by OnStop in order, then ActionContinue.
+		lines []int
+		// lines are the lines stopped at.
+	}{
+		{"breakpoints", []int{2, 6}, false, nil, []int{2, 6, 2}},
+		{"step over", nil, true, []vm.Action{vm.ActionStepOver, vm.ActionStepOver, vm.ActionStepOver}, []int{1, 5, 6, 7}},
+		{"step into", nil, true, []vm.Action{vm.ActionStepOver, vm.ActionStepInto, vm.ActionStepInto}, []int{1, 5, 2, 3}},
+		{"step out", []int{2}, false, []vm.Action{vm.ActionStepOut}, []int{2, 6, 2}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var lines []int
+			d := vm.NewDebugger(func(v *vm.VM, stop vm.Stop) vm.Action {
+				lines = append(lines, stop.Pos.Line)
+				if len(lines) > len(c.actions) {
+					return vm.ActionContinue
+				}
+				return c.actions[len(lines)-1]
+			})
+			d.SetBreakpoints("debug", c.breaks)
+			if c.pause {
+				d.Pause()
+			}
+			result, err := tc.Eval(TestCaseConfig{Engine: EngineVM, Debugger: d})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := result.String(); s != "111" {
+				t.Errorf("want 111, got %s", s)
+			}
+			if !reflect.DeepEqual(lines, c.lines) {
+				t.Errorf("want stops at lines %v, got %v", c.lines, lines)
+			}
+		})
+	}
+}
+
+func TestDebuggerFrames(t *testing.T) {
+	tc := testCase(t, "debug", debugSrc)
+	var frames []vm.Frame
+	d := vm.NewDebugger(func(v *vm.VM, stop vm.Stop) vm.Action {
+		frames = v.Frames()
+		return vm.ActionQuit
+	})
+	d.AddBreakpoint(vm.Breakpoint{Filename: "debug", Line: 3})
+	_, err := tc.Eval(TestCaseConfig{Engine: EngineVM, Debugger: d})
+	if !errors.Is(err, vm.ErrQuit) {
+		t.Fatalf("want ErrQuit, got %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("want 2 frames, got %d", len(frames))
+	}
+	f := frames[0]
+	if len(f.Args) != 1 || fmt.Sprint(f.Args[0]) != "3" {
+		t.Errorf("want args [3], got %v", f.Args)
+	}
+	if len(f.Vars) != 1 || f.Vars[0].Name != "x" || fmt.Sprint(f.Vars[0].Value) != "9" {
+		t.Errorf("want vars [x 9], got %v", f.Vars)
+	}
+}
+
+func TestDebuggerEngines(t *testing.T) {
+	tc := testCase(t, "debug", debugSrc)
+	cases := []struct {
+		name    string
+		breaks  []int
+		pause   bool
+		actions []parser.Action
+		// actions are returned by OnStop in order, then ActionContinue.
+		lines []int
+		// lines are the lines stopped at.
+	}{
+		{"breakpoints", []int{2, 6}, false, nil, []int{2, 6, 2}},
+		{"step over", nil, true, []parser.Action{parser.ActionStepOver, parser.ActionStepOver, parser.ActionStepOver}, []int{1, 5, 6, 7}},
+		{"step into", nil, true, []parser.Action{parser.ActionStepOver, parser.ActionStepInto, parser.ActionStepInto}, []int{1, 5, 2, 3}},
+		{"step out", []int{2}, false, []parser.Action{parser.ActionStepOut}, []int{2, 6, 2}},
+	}
+	for _, c := range cases {
+		for _, cfg := range TestCaseConfigs {
+			c := c
+			t.Run(c.name+" "+cfg.String(), func(t *testing.T) {
+				var lines []int
+				cfg.Debugger = parser.NewDebugger(func(p parser.Paused, stop parser.Stop) parser.Action {
+					lines = append(lines, stop.Pos.Line)
+					if len(lines) > len(c.actions) {
+						return parser.ActionContinue
+					}
+					return c.actions[len(lines)-1]
+				})
+				cfg.Debugger.SetBreakpoints("debug", c.breaks)
+				if c.pause {
+					cfg.Debugger.Pause()
+				}
+				result, err := tc.Eval(cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if s := result.String(); s != "111" {
+					t.Errorf("want 111, got %s", s)
+				}
+				if !reflect.DeepEqual(lines, c.lines) {
+					t.Errorf("want stops at lines %v, got %v", c.lines, lines)
+				}
+			})
+		}
+	}
+}
+
+func TestDebuggerFramesEngines(t *testing.T) {
+	tc := testCase(t, "debug", debugSrc)
+	for _, cfg := range TestCaseConfigs {
+		t.Run(cfg.String(), func(t *testing.T) {
+			var frames []parser.Frame
+			var watch parser.Evaler
+			var watchErr error
+			cfg.Debugger = parser.NewDebugger(func(p parser.Paused, stop parser.Stop) parser.Action {
+				frames = p.Frames()
+				watch, watchErr = p.Eval(0, "(@add x $0)")
+				return parser.ActionQuit
+			})
+			cfg.Debugger.AddBreakpoint(parser.Breakpoint{Filename: "debug", Line: 3})
+			_, err := tc.Eval(cfg)
+			if !errors.Is(err, parser.ErrQuit) {
+				t.Fatalf("want ErrQuit, got %v", err)
+			}
+			if len(frames) < 2 {
+				t.Fatalf("want at least 2 frames, got %d", len(frames))
+			}
+			vars := map[string]string{}
+			for _, s := range frames[0].Scopes {
+				for _, v := range s.Vars {
+					vars[v.Name] = fmt.Sprint(v.Value)
+				}
+			}
+			if vars["x"] != "9" {
+				t.Errorf("want x = 9, got %v", vars)
+			}
+			if watchErr != nil {
+				t.Fatal(watchErr)
+			}
+			if s := watch.String(); s != "12" {
+				t.Errorf("want watch 12, got %s", s)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+func TestDeterministic(t *testing.T) {
+	tc := testCase(t, "deterministic", "(@def a (@add 1 2))\n(@def b (@add 3 4))\n(@def c (@add a 5))\n(@add (@add a b) c)")
+	for _, cfg := range TestCaseConfigs {
+		for seed := int64(0); seed < 8; seed++ {
+			cfg.Deterministic = parser.NewDeterministic(seed)
+			result, err := tc.Eval(cfg)
+			if err != nil {
+				t.Fatalf("%s seed %d: %s", cfg, seed, err)
+			}
+			if s := result.String(); s != "18" {
+				t.Errorf("%s seed %d: want 18, got %s", cfg, seed, s)
+			}
+		}
+	}
+}
+
+func TestDeterministicOrder(t *testing.T) {
+	order := func(seed int64) []int {
+		d := parser.NewDeterministic(seed)
+		re := make([]int, 0, 6)
+		// 0 1 2 are independent, 3 depends on 0 and 1, 4 and 5 on 3
+		deps := []int{0, 0, 0, 2, 1, 1}
+		reverseDeps := [][]int{{3}, {3}, nil, {4, 5}, nil, nil}
+		d.Run(deps, func(i int) []int {
+			re = append(re, i)
+			return reverseDeps[i]
+		})
+		return re
+	}
+	for seed := int64(0); seed < 8; seed++ {
+		a, b := order(seed), order(seed)
+		if len(a) != 6 {
+			t.Fatalf("seed %d: want 6 tasks run, got %v", seed, a)
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("seed %d: orders differ: %v and %v", seed, a, b)
+			}
+		}
+		pos := map[int]int{}
+		for i, task := range a {
+			pos[task] = i
+		}
+		if pos[3] < pos[0] || pos[3] < pos[1] || pos[4] < pos[3] || pos[5] < pos[3] {
+			t.Errorf("seed %d: deps not respected: %v", seed, a)
+		}
+	}
+}
+
+func TestDeterministicTime(t *testing.T) {
+	tc := testCase(t, "time", "(@time_now)")
+	for _, cfg := range TestCaseConfigs {
+		d := parser.NewDeterministic(0)
+		cfg.Deterministic = d
+		result, err := tc.Eval(cfg)
+		if err != nil {
+			t.Fatalf("%s: %s", cfg, err)
+		}
+		got := result.(*parser.Time).Time
+		if !got.Equal(d.Epoch) {
+			t.Errorf("%s: want %s, got %s", cfg, d.Epoch, got)
+		}
+		d.Sleep(2 * time.Second)
+		want := d.Epoch.Add(d.Tick + 2*time.Second)
+		if got := d.Now(); !got.Equal(want) {
+			t.Errorf("%s: want %s, got %s", cfg, want, got)
+		}
+	}
+}
+
+func TestMapSorted(t *testing.T) {
+	m := &parser.Map{Content: map[string]parser.Evaler{}}
+	for _, key := range []string{"d", "b", "a", "e", "c"} {
+		m.Content[key] = parser.NewString(key)
+	}
+	for i, want := range []string{"a", "b", "c", "d", "e"} {
+		key, _ := m.Index(i)
+		if got := key.(*parser.String).Content; got != want {
+			t.Errorf("%d: want %s, got %s", i, want, got)
+		}
+	}
+}
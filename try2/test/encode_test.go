@@ -0,0 +1,75 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/compile"
+	"gitlab.com/coalang/go-coa/try2/encode"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	sources := map[string]string{
+		"add":  source_add,
+		"add2": source_add2,
+		"func": source_func,
+		"loop": source_loop,
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			tc, err := NewTestCase(name, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ce := compile.NewEnv(lexer.Position{Filename: "root"})
+			insts, err := ce.NewScope().CompileNodes(*tc.root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b := new(bytes.Buffer)
+			err = encode.Encode(b, &encode.Program{Env: ce, Insts: insts})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := encode.Decode(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(compile.Instructions(insts), p.Insts) {
+				t.Fatalf("instructions differ:\nwant:\n%s\ngot:\n%s", compile.Instructions(insts), p.Insts)
+			}
+			if p.Env.Pos != ce.Pos {
+				t.Fatalf("pos: want %s, got %s", ce.Pos, p.Env.Pos)
+			}
+		})
+	}
+}
+
+func TestDecodeBadCount(t *testing.T) {
+	// a huge count of instructions, then the end of the input
+	data := append([]byte(encode.Magic), encode.Version, 0, 0, 0, 0)
+	data = append(data, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f)
+	_, err := encode.Decode(bytes.NewReader(data))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("want io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	// a huge length of the filename of the position
+	data = append([]byte(encode.Magic), encode.Version, 0xff, 0xff, 0xff, 0x7f)
+	_, err = encode.Decode(bytes.NewReader(data))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("want io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestDecodeBadMagic(t *testing.T) {
+	_, err := encode.Decode(bytes.NewBufferString("(@add 1 2)"))
+	if err != encode.ErrMagic {
+		t.Fatalf("want ErrMagic, got %v", err)
+	}
+}
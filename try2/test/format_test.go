@@ -0,0 +1,61 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/format"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"single line", "(@def  a   [1 2\t3])", "(@def a [1 2 3])\n"},
+		{"indent", "(@def f {\n        (@add 1 2)\n  (@sub 1 2)})\n", "(@def f {\n\t(@add 1 2)\n\t(@sub 1 2)\n})\n"},
+		{"groups", "(@if\n(@eq x 0) 0\n  x)", "(@if\n\t(@eq x 0) 0\n\tx\n)\n"},
+		{"map", "[m'a' 1\n'b' 2]", "[m 'a' 1\n\t'b' 2\n]\n"},
+		{"single line map", "[m  x 1]", "[m x 1]\n"},
+		{"ambiguous list", "[ m]", "[ m ]\n"},
+		{"blank lines", "a\n\n\n\nb\nc", "a\n\nb\nc\n"},
+		{"comments", "# head\n(@a 1 # one\n  2\n  # lone\n  3) # tail\n{ # open\n}\n# end", "# head\n(@a 1 # one\n\t2\n\t# lone\n\t3\n) # tail\n{ # open\n}\n# end\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := format.Source(c.name, []byte(c.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != c.want {
+				t.Fatalf("want:\n%s\ngot:\n%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	paths, err := filepath.Glob("tests/*.coa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			once, err := format.Source(path, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			twice, err := format.Source(path, once)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(once) != string(twice) {
+				t.Fatalf("formatting again changed:\n%s\nto:\n%s", once, twice)
+			}
+		})
+	}
+}
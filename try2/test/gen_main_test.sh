@@ -23,48 +23,51 @@ cat << EOF
 //go:embed $path
 var source_$name string
 
+//go:embed ${path%.coa}.golden
+var golden_$name []byte
+
 func BenchmarkGen${name}_IS(b *testing.B) {
 	$(test_case_call "$name")
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_$name)
 }
 
 func BenchmarkGen${name}_IP(b *testing.B) {
 	$(test_case_call "$name")
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_$name)
 }
 
 func BenchmarkGen${name}_VS(b *testing.B) {
 	$(test_case_call "$name")
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_$name)
 }
 
 func BenchmarkGen${name}_VP(b *testing.B) {
 	$(test_case_call "$name")
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_$name)
 }
 
 func TestGen${name}_IS(t *testing.T) {
 	b := t
 	$(test_case_call "$name")
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_$name)
 }
 
 func TestGen${name}_IP(t *testing.T) {
 	b := t
 	$(test_case_call "$name")
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_$name)
 }
 
 func TestGen${name}_VS(t *testing.T) {
 	b := t
 	$(test_case_call "$name")
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_$name)
 }
 
 func TestGen${name}_VP(t *testing.T) {
 	b := t
 	$(test_case_call "$name")
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_$name)
 }
 EOF
 }
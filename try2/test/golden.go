@@ -0,0 +1,187 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+// Golden is the expected output of a test case, usually read from a .golden file
+// next to its source (see ParseGolden).
+type Golden struct {
+	Stdout string
+	Result string
+	// Result is the inspected value of the last node, or empty if there is none.
+	Error string
+	// Error is the message of the innermost error, or empty if there is none.
+	// In a .golden file, it only needs to be part of the message (see Matches).
+}
+
+var goldenSections = []string{"stdout", "result", "error"}
+
+// ParseGolden parses a .golden file, which has a section for each of stdout,
+// result and error:
+//
+//	-- stdout --
+//	hello
+//	-- result --
+//	3
+//	-- error --
+//
+// Sections which are missing are empty.
+func ParseGolden(data []byte) (Golden, error) {
+	sections := map[string]*strings.Builder{}
+	var cur *strings.Builder
+	for i, line := range strings.SplitAfter(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "-- ") && strings.HasSuffix(trimmed, " --") {
+			name := strings.TrimSpace(trimmed[3 : len(trimmed)-3])
+			if !isGoldenSection(name) {
+				return Golden{}, fmt.Errorf("line %d: unknown section %s", i+1, name)
+			}
+			if _, ok := sections[name]; ok {
+				return Golden{}, fmt.Errorf("line %d: duplicate section %s", i+1, name)
+			}
+			cur = new(strings.Builder)
+			sections[name] = cur
+			continue
+		}
+		if cur == nil {
+			if trimmed != "" {
+				return Golden{}, fmt.Errorf("line %d: text outside of sections", i+1)
+			}
+			continue
+		}
+		cur.WriteString(line)
+	}
+	get := func(name string) string {
+		if b, ok := sections[name]; ok {
+			return b.String()
+		}
+		return ""
+	}
+	return Golden{
+		Stdout: get("stdout"),
+		Result: strings.TrimSuffix(get("result"), "\n"),
+		Error:  strings.TrimSuffix(get("error"), "\n"),
+	}, nil
+}
+
+func isGoldenSection(name string) bool {
+	for _, s := range goldenSections {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Bytes returns g in the format read by ParseGolden.
+// A newline is added to the end of stdout if missing.
+func (g Golden) Bytes() []byte {
+	b := new(bytes.Buffer)
+	section := func(name, content string) {
+		fmt.Fprintf(b, "-- %s --\n", name)
+		b.WriteString(content)
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	section("stdout", g.Stdout)
+	section("result", g.Result)
+	section("error", g.Error)
+	return b.Bytes()
+}
+
+// Matches returns whether got has the output g expects: the same stdout and
+// result, and an error containing g.Error (or none if g.Error is empty).
+func (g Golden) Matches(got Golden) bool {
+	if g.Stdout != got.Stdout || g.Result != got.Result {
+		return false
+	}
+	if g.Error == "" {
+		return got.Error == ""
+	}
+	return strings.Contains(got.Error, g.Error)
+}
+
+// Agrees returns whether g and other have the same stdout, result and error.
+func (g Golden) Agrees(other Golden) bool {
+	return g == other
+}
+
+// Golden runs tc once with cfg, and returns what it wrote to stdout, its result
+// and its error.
+// The Stdio of cfg is replaced, with no input.
+func (tc *TestCase) Golden(cfg TestCaseConfig) Golden {
+	stdout := new(bytes.Buffer)
+	cfg.Stdio = &parser.Stdio{In: new(bytes.Buffer), Out: stdout}
+	result, err := tc.Eval(cfg)
+
+	g := Golden{Stdout: stdout.String()}
+	if result != nil {
+		g.Result = util.ToInspect(result)
+	}
+	if err != nil {
+		g.Error = errs.Message(err)
+	}
+	return g
+}
+
+// Diff returns the lines which differ between a and b, prefixed with - and +
+// respectively, and the lines in common prefixed with spaces.
+func Diff(a, b string) string {
+	as, bs := strings.SplitAfter(a, "\n"), strings.SplitAfter(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of as[i:] and bs[j:]
+	lcs := make([][]int, len(as)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	re := new(strings.Builder)
+	line := func(prefix, s string) {
+		if s == "" {
+			return
+		}
+		re.WriteString(prefix + s)
+		if !strings.HasSuffix(s, "\n") {
+			re.WriteString("\n")
+		}
+	}
+	i, j := 0, 0
+	for i < len(as) && j < len(bs) {
+		switch {
+		case as[i] == bs[j]:
+			line("  ", as[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			line("- ", as[i])
+			i++
+		default:
+			line("+ ", bs[j])
+			j++
+		}
+	}
+	for ; i < len(as); i++ {
+		line("- ", as[i])
+	}
+	for ; j < len(bs); j++ {
+		line("+ ", bs[j])
+	}
+	return re.String()
+}
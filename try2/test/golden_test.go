@@ -0,0 +1,62 @@
+package test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "write .golden files from the output of the interpreter")
+
+// TestGolden runs each tests/*.coa with each of TestCaseConfigs, and checks that
+// they agree and match tests/*.golden.
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob("tests/*.coa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		path := path
+		name := strings.TrimSuffix(filepath.Base(path), ".coa")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc := testCase(t, path, string(src))
+			gs := make([]Golden, len(TestCaseConfigs))
+			for i, cfg := range TestCaseConfigs {
+				gs[i] = tc.Golden(cfg)
+			}
+			for i, g := range gs[1:] {
+				if !gs[0].Agrees(g) {
+					t.Errorf("%s and %s disagree:\n%s", TestCaseConfigs[0], TestCaseConfigs[i+1], Diff(string(gs[0].Bytes()), string(g.Bytes())))
+				}
+			}
+
+			goldenPath := strings.TrimSuffix(path, ".coa") + ".golden"
+			if *update {
+				err = os.WriteFile(goldenPath, gs[0].Bytes(), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			data, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%s (run with -update to make it)", err)
+			}
+			want, err := ParseGolden(data)
+			if err != nil {
+				t.Fatalf("%s: %s", goldenPath, err)
+			}
+			for i, g := range gs {
+				if !want.Matches(g) {
+					t.Errorf("%s does not match %s:\n%s", TestCaseConfigs[i], goldenPath, Diff(string(want.Bytes()), string(g.Bytes())))
+				}
+			}
+		})
+	}
+}
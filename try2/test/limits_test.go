@@ -0,0 +1,66 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		name, src string
+		limits    parser.Limits
+		limit     string
+		vmOnly    bool
+	}{
+		{"instructions", `(@while @true {1})`, parser.Limits{Instructions: 1000}, parser.LimitInstructions, true},
+		{"call depth", "(@def a {1})\n(@def b {(a)})\n(@def c {(b)})\n(c)", parser.Limits{CallDepth: 2}, parser.LimitCallDepth, false},
+		{"list length", `(@map (@range 100) {$0})`, parser.Limits{ListLen: 10}, parser.LimitListLen, false},
+		{"string size", `(@concat "abc" "def")`, parser.Limits{StringSize: 4}, parser.LimitStringSize, false},
+	}
+	for _, c := range cases {
+		tc := testCase(t, c.name, c.src)
+		for _, cfg := range TestCaseConfigs {
+			if c.vmOnly && cfg.Engine != EngineVM {
+				continue
+			}
+			c := c
+			t.Run(c.name+" "+cfg.String(), func(t *testing.T) {
+				cfg.Limits = &c.limits
+				done := make(chan error)
+				go func() {
+					_, err := tc.Eval(cfg)
+					done <- err
+				}()
+				select {
+				case err := <-done:
+					var limitErr *parser.LimitError
+					if !errors.As(err, &limitErr) {
+						t.Fatalf("want *parser.LimitError, got %v", err)
+					}
+					if limitErr.Limit != c.limit {
+						t.Errorf("limit: want %s, got %s", c.limit, limitErr.Limit)
+					}
+					if limitErr.Pos.Line == 0 {
+						t.Errorf("no position: %s", limitErr)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("not limited")
+				}
+			})
+		}
+	}
+}
+
+func TestLimitsUnder(t *testing.T) {
+	tc := testCase(t, "under", `(@concat "abc" "def")`)
+	for _, cfg := range TestCaseConfigs {
+		cfg.Limits = &parser.Limits{Instructions: 1000, CallDepth: 50, ListLen: 10, StringSize: 6}
+		_, err := tc.Eval(cfg)
+		if err != nil {
+			t.Errorf("%s: %s", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/lsp"
+)
+
+// lspClient drives an lsp.Server over pipes.
+type lspClient struct {
+	t        *testing.T
+	w        io.WriteCloser
+	messages chan *lsp.Message
+	id       int
+
+	notifications []*lsp.Message
+}
+
+func newLSPClient(t *testing.T) *lspClient {
+	s := lsp.NewServer(lsp.BuiltinDocs())
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		err := s.Serve(inR, outW)
+		if err != nil {
+			t.Error(err)
+		}
+		_ = outW.Close()
+	}()
+	c := &lspClient{t: t, w: inW, messages: make(chan *lsp.Message, 16)}
+	go func() {
+		// read messages as they come, as writes to pipes block until read
+		defer close(c.messages)
+		r := bufio.NewReader(outR)
+		for {
+			m, err := lsp.ReadMessage(r)
+			if err != nil {
+				return
+			}
+			c.messages <- m
+		}
+	}()
+	c.request("initialize", map[string]interface{}{}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	t.Cleanup(func() {
+		c.request("shutdown", nil, nil)
+		c.notify("exit", nil)
+		_ = c.w.Close()
+	})
+	return c
+}
+
+func (c *lspClient) send(m *lsp.Message, params interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	m.JSONRPC = "2.0"
+	m.Params = data
+	err = lsp.WriteMessage(c.w, m)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *lspClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(&lsp.Message{Method: method}, params)
+}
+
+// request sends a request, and decodes the result of its response into result.
+// Notifications received meanwhile are kept.
+func (c *lspClient) request(method string, params interface{}, result interface{}) {
+	c.t.Helper()
+	c.id++
+	id, _ := json.Marshal(c.id)
+	c.send(&lsp.Message{ID: id, Method: method}, params)
+	for m := range c.messages {
+		if m.ID == nil {
+			c.notifications = append(c.notifications, m)
+			continue
+		}
+		if string(m.ID) != string(id) {
+			c.t.Fatalf("response for %s, want %s", m.ID, id)
+		}
+		if m.Error != nil {
+			c.t.Fatalf("%s: %s", method, m.Error)
+		}
+		if result != nil {
+			err := json.Unmarshal(m.Result, result)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+	c.t.Fatalf("%s: no response", method)
+}
+
+// open opens a document and returns its diagnostics.
+func (c *lspClient) open(uri, text string) []lsp.Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "coa", Version: 1, Text: text},
+	})
+	// a request makes sure the notification was handled
+	c.request("textDocument/completion", position(uri, 0, 0), nil)
+	for i := len(c.notifications) - 1; i >= 0; i-- {
+		m := c.notifications[i]
+		if m.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params lsp.PublishDiagnosticsParams
+		err := json.Unmarshal(m.Params, &params)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+	c.t.Fatalf("no diagnostics published for %s", uri)
+	return nil
+}
+
+func position(uri string, line, character int) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: character},
+	}
+}
+
+// span returns the range from character start to end on line.
+func span(line, start, end int) lsp.Range {
+	return lsp.Range{
+		Start: lsp.Position{Line: line, Character: start},
+		End:   lsp.Position{Line: line, Character: end},
+	}
+}
+
+func TestBuiltinDocs(t *testing.T) {
+	src, err := os.ReadFile("../main/builtin.coa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lsp.ParseDocs(string(src)), lsp.BuiltinDocs()) {
+		t.Error("docs differ from main/builtin.coa (run make in lsp)")
+	}
+}
+
+func TestLSPDiagnostics(t *testing.T) {
+	c := newLSPClient(t)
+	cases := []struct {
+		name, text string
+		want       []lsp.Range
+		message    string
+	}{
+		{"ok", "(@def a 1)\n(@io_outln a)\n", nil, ""},
+		{"unexpected token", "(@def a 1)\n(@def b ]\n", []lsp.Range{span(1, 8, 9)}, "unexpected token"},
+		{"undefined", "(@def a 1)\n(@io_outln (@add a b))\n", []lsp.Range{span(1, 19, 20)}, "b is not defined"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			diagnostics := c.open("file:///"+tc.name+".coa", tc.text)
+			if len(diagnostics) != len(tc.want) {
+				t.Fatalf("want %d diagnostics, got %v", len(tc.want), diagnostics)
+			}
+			for i, d := range diagnostics {
+				if d.Range != tc.want[i] {
+					t.Errorf("range: want %v, got %v", tc.want[i], d.Range)
+				}
+				if !strings.Contains(d.Message, tc.message) {
+					t.Errorf("message: want %q in %q", tc.message, d.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestLSPHover(t *testing.T) {
+	c := newLSPClient(t)
+	uri := "file:///hover.coa"
+	c.open(uri, "(@def a (@add 1 2))\n")
+	var hover lsp.Hover
+	c.request("textDocument/hover", position(uri, 0, 11), &hover)
+	if !strings.Contains(hover.Contents.Value, "(@add a b)") || !strings.Contains(hover.Contents.Value, "a + b") {
+		t.Fatalf("unexpected hover %q", hover.Contents.Value)
+	}
+}
+
+func TestLSPDefinition(t *testing.T) {
+	c := newLSPClient(t)
+	uri := "file:///definition.coa"
+	c.open(uri, "(@def a 1)\n(@def f {\n\t(@io_outln a)\n})\n(@def a 2)\n(f)\n")
+	var loc lsp.Location
+	c.request("textDocument/definition", position(uri, 2, 12), &loc)
+	want := lsp.Location{URI: uri, Range: span(0, 6, 7)}
+	if loc != want {
+		t.Fatalf("want %v, got %v", want, loc)
+	}
+	c.request("textDocument/definition", position(uri, 5, 1), &loc)
+	want.Range = span(1, 6, 7)
+	if loc != want {
+		t.Fatalf("want %v, got %v", want, loc)
+	}
+}
+
+func TestLSPCompletion(t *testing.T) {
+	c := newLSPClient(t)
+	uri := "file:///completion.coa"
+	c.open(uri, "(@def counter 1)\n")
+	var items []lsp.CompletionItem
+	c.request("textDocument/completion", position(uri, 1, 0), &items)
+	labels := map[string]lsp.CompletionItem{}
+	for _, item := range items {
+		labels[item.Label] = item
+	}
+	if item, ok := labels["@io_outln"]; !ok || item.Detail != "(@io_outln content)" {
+		t.Errorf("@io_outln: got %v", item)
+	}
+	if _, ok := labels["counter"]; !ok {
+		t.Errorf("counter not completed")
+	}
+}
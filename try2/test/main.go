@@ -2,11 +2,15 @@ package test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/alecthomas/participle/v2/lexer"
 	"gitlab.com/coalang/go-coa/try2/compile"
 	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/prof"
+	"gitlab.com/coalang/go-coa/try2/trace"
 	"gitlab.com/coalang/go-coa/try2/vm"
 )
 
@@ -52,26 +56,77 @@ const (
 type TestCaseConfig struct {
 	Engine   Engine
 	Parallel bool
+	Guard    parser.ResourcesGuard
+	// Guard is the ResourcesGuard of the Env or VM, if not nil.
+	Ctx context.Context
+	// Ctx is the context of the Env or VM, if not nil.
+	Limits *parser.Limits
+	// Limits is the Limits of the Env or VM, if not nil.
+	Stdio *parser.Stdio
+	// Stdio is the Stdio of the Env or VM, if not nil.
+	Profile *prof.Profile
+	// Profile is the Profile of the Env or VM, if not nil.
+	Deterministic *parser.Deterministic
+	// Deterministic is the Deterministic of the Env or VM, if not nil.
+	Tracer *trace.Tracer
+	// Tracer is the Tracer of the Env or VM, if not nil.
+	Debugger *parser.Debugger
+	// Debugger is the Debugger of the Env or VM, if not nil.
 }
 
-func (tc *TestCase) Run(b *testing.B, cfg TestCaseConfig) {
+// String returns the suffix of the generated tests for cfg, e.g. IS for the
+// interpreter evaluating serially.
+func (cfg TestCaseConfig) String() string {
+	var re string
+	switch cfg.Engine {
+	case EngineInterp:
+		re = "I"
+	case EngineVM:
+		re = "V"
+	default:
+		re = "?"
+	}
+	if cfg.Parallel {
+		return re + "P"
+	}
+	return re + "S"
+}
+
+// TestCaseConfigs are the configs which tests run with.
+var TestCaseConfigs = []TestCaseConfig{
+	{Engine: EngineInterp, Parallel: false},
+	{Engine: EngineInterp, Parallel: true},
+	{Engine: EngineVM, Parallel: false},
+	{Engine: EngineVM, Parallel: true},
+}
+
+// Run benchmarks tc with cfg. Errors fail the benchmark, unless golden (the
+// contents of its .golden file) expects one.
+func (tc *TestCase) Run(b *testing.B, cfg TestCaseConfig, golden []byte) {
+	want, err := ParseGolden(golden)
+	if err != nil {
+		b.Fatal(err)
+	}
+	check := func(err error) {
+		if err != nil && want.Error == "" {
+			b.Fatal(err)
+		}
+	}
 	switch cfg.Engine {
 	case EngineInterp:
 		env := parser.NewEnv(lexer.Position{
 			Filename: "root",
-		}, false)
+		}, cfg.Parallel)
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
 			_, err := tc.root.Eval(env)
-			if err != nil {
-				b.Fatal(err)
-			}
+			check(err)
 		}
 	case EngineVM:
 		var insts []compile.Instruction
-		var err error
 		{
 			ce := compile.NewEnv(lexer.Position{Filename: "root"})
+			ce.Parallel = cfg.Parallel
 			s := ce.NewScope()
 			insts, err = s.CompileNodes(*tc.root)
 			if err != nil {
@@ -85,10 +140,7 @@ func (tc *TestCase) Run(b *testing.B, cfg TestCaseConfig) {
 			// log.Println(compile.Instructions(insts))
 			b.ResetTimer()
 			for i := 0; i < b.N; i++ {
-				err = v.Execute(p)
-				if err != nil {
-					b.Fatal(err)
-				}
+				check(v.Execute(p))
 			}
 		}
 
@@ -97,39 +149,60 @@ func (tc *TestCase) Run(b *testing.B, cfg TestCaseConfig) {
 	}
 }
 
-func (tc *TestCase) Test(t *testing.T, cfg TestCaseConfig) {
+// Test runs tc once with cfg, and checks that its output matches golden, the
+// contents of its .golden file (see ParseGolden).
+func (tc *TestCase) Test(t *testing.T, cfg TestCaseConfig, golden []byte) {
+	want, err := ParseGolden(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tc.Golden(cfg)
+	if !want.Matches(got) {
+		t.Fatalf("does not match golden:\n%s", Diff(string(want.Bytes()), string(got.Bytes())))
+	}
+}
+
+// Eval runs tc once with cfg, and returns the value of its last node.
+func (tc *TestCase) Eval(cfg TestCaseConfig) (parser.Evaler, error) {
 	switch cfg.Engine {
 	case EngineInterp:
 		env := parser.NewEnv(lexer.Position{
 			Filename: "root",
-		}, false)
-		_, err := tc.root.Eval(env)
+		}, cfg.Parallel)
+		env.ResourcesGuard = cfg.Guard
+		env.Ctx = cfg.Ctx
+		env.Limits = cfg.Limits
+		env.Stdio = cfg.Stdio
+		env.Profile = cfg.Profile
+		env.Tracer = cfg.Tracer
+		env.Deterministic = cfg.Deterministic
+		env.Debugger = cfg.Debugger
+		return tc.root.Eval(env)
+	case EngineVM:
+		ce := compile.NewEnv(lexer.Position{Filename: "root"})
+		ce.Parallel = cfg.Parallel
+		insts, err := ce.NewScope().CompileNodes(*tc.root)
 		if err != nil {
-			t.Fatal(err)
+			return nil, err
 		}
-	case EngineVM:
-		var insts []compile.Instruction
-		var err error
-		{
-			ce := compile.NewEnv(lexer.Position{Filename: "root"})
-			s := ce.NewScope()
-			insts, err = s.CompileNodes(*tc.root)
-			if err != nil {
-				t.Fatal(err)
-			}
+		v := vm.NewVM()
+		v.ResourcesGuard = cfg.Guard
+		v.Limits = cfg.Limits
+		v.Stdio = cfg.Stdio
+		v.Profile = cfg.Profile
+		v.Deterministic = cfg.Deterministic
+		v.Debugger = cfg.Debugger
+		v.Tracer = cfg.Tracer
+		ctx := cfg.Ctx
+		if ctx == nil {
+			ctx = context.Background()
 		}
-
-		{
-			p := vm.NewProgram(insts)
-			v := vm.NewVM()
-			// log.Println(compile.Instructions(insts))
-			err = v.Execute(p)
-			if err != nil {
-				t.Fatal(err)
-			}
+		err = v.ExecuteContext(ctx, vm.NewProgram(insts))
+		if err != nil {
+			return nil, err
 		}
-
+		return v.Result(), nil
 	default:
-		t.Fatal("unsupported engine")
+		return nil, errors.New("unsupported engine")
 	}
 }
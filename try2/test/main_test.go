@@ -6,194 +6,461 @@ import (
 	_ "embed"
 )
 
+// Test case add (tests/add.coa)
+//go:embed tests/add.coa
+var source_add string
+
+//go:embed tests/add.golden
+var golden_add []byte
+
+func BenchmarkGenadd_IS(b *testing.B) {
+	tc := testCase(b, "add", source_add)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_add)
+}
+
+func BenchmarkGenadd_IP(b *testing.B) {
+	tc := testCase(b, "add", source_add)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_add)
+}
+
+func BenchmarkGenadd_VS(b *testing.B) {
+	tc := testCase(b, "add", source_add)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_add)
+}
+
+func BenchmarkGenadd_VP(b *testing.B) {
+	tc := testCase(b, "add", source_add)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_add)
+}
+
+func TestGenadd_IS(t *testing.T) {
+	b := t
+	tc := testCase(b, "add", source_add)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_add)
+}
+
+func TestGenadd_IP(t *testing.T) {
+	b := t
+	tc := testCase(b, "add", source_add)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_add)
+}
+
+func TestGenadd_VS(t *testing.T) {
+	b := t
+	tc := testCase(b, "add", source_add)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_add)
+}
+
+func TestGenadd_VP(t *testing.T) {
+	b := t
+	tc := testCase(b, "add", source_add)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_add)
+}
+
 // Test case add2 (tests/add2.coa)
 //go:embed tests/add2.coa
 var source_add2 string
 
+//go:embed tests/add2.golden
+var golden_add2 []byte
+
 func BenchmarkGenadd2_IS(b *testing.B) {
 	tc := testCase(b, "add2", source_add2)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_add2)
 }
 
 func BenchmarkGenadd2_IP(b *testing.B) {
 	tc := testCase(b, "add2", source_add2)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_add2)
 }
 
 func BenchmarkGenadd2_VS(b *testing.B) {
 	tc := testCase(b, "add2", source_add2)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_add2)
 }
 
 func BenchmarkGenadd2_VP(b *testing.B) {
 	tc := testCase(b, "add2", source_add2)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_add2)
 }
 
 func TestGenadd2_IS(t *testing.T) {
 	b := t
 	tc := testCase(b, "add2", source_add2)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_add2)
 }
 
 func TestGenadd2_IP(t *testing.T) {
 	b := t
 	tc := testCase(b, "add2", source_add2)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_add2)
 }
 
 func TestGenadd2_VS(t *testing.T) {
 	b := t
 	tc := testCase(b, "add2", source_add2)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_add2)
 }
 
 func TestGenadd2_VP(t *testing.T) {
 	b := t
 	tc := testCase(b, "add2", source_add2)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_add2)
 }
 
-// Test case add (tests/add.coa)
-//go:embed tests/add.coa
-var source_add string
+// Test case control (tests/control.coa)
+//go:embed tests/control.coa
+var source_control string
 
-func BenchmarkGenadd_IS(b *testing.B) {
-	tc := testCase(b, "add", source_add)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+//go:embed tests/control.golden
+var golden_control []byte
+
+func BenchmarkGencontrol_IS(b *testing.B) {
+	tc := testCase(b, "control", source_control)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_control)
 }
 
-func BenchmarkGenadd_IP(b *testing.B) {
-	tc := testCase(b, "add", source_add)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+func BenchmarkGencontrol_IP(b *testing.B) {
+	tc := testCase(b, "control", source_control)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_control)
 }
 
-func BenchmarkGenadd_VS(b *testing.B) {
-	tc := testCase(b, "add", source_add)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false})
+func BenchmarkGencontrol_VS(b *testing.B) {
+	tc := testCase(b, "control", source_control)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_control)
 }
 
-func BenchmarkGenadd_VP(b *testing.B) {
-	tc := testCase(b, "add", source_add)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true})
+func BenchmarkGencontrol_VP(b *testing.B) {
+	tc := testCase(b, "control", source_control)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_control)
 }
 
-func TestGenadd_IS(t *testing.T) {
+func TestGencontrol_IS(t *testing.T) {
 	b := t
-	tc := testCase(b, "add", source_add)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc := testCase(b, "control", source_control)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_control)
 }
 
-func TestGenadd_IP(t *testing.T) {
+func TestGencontrol_IP(t *testing.T) {
 	b := t
-	tc := testCase(b, "add", source_add)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc := testCase(b, "control", source_control)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_control)
 }
 
-func TestGenadd_VS(t *testing.T) {
+func TestGencontrol_VS(t *testing.T) {
 	b := t
-	tc := testCase(b, "add", source_add)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc := testCase(b, "control", source_control)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_control)
 }
 
-func TestGenadd_VP(t *testing.T) {
+func TestGencontrol_VP(t *testing.T) {
 	b := t
-	tc := testCase(b, "add", source_add)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc := testCase(b, "control", source_control)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_control)
+}
+
+// Test case error (tests/error.coa)
+//go:embed tests/error.coa
+var source_error string
+
+//go:embed tests/error.golden
+var golden_error []byte
+
+func BenchmarkGenerror_IS(b *testing.B) {
+	tc := testCase(b, "error", source_error)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_error)
+}
+
+func BenchmarkGenerror_IP(b *testing.B) {
+	tc := testCase(b, "error", source_error)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_error)
+}
+
+func BenchmarkGenerror_VS(b *testing.B) {
+	tc := testCase(b, "error", source_error)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_error)
+}
+
+func BenchmarkGenerror_VP(b *testing.B) {
+	tc := testCase(b, "error", source_error)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_error)
+}
+
+func TestGenerror_IS(t *testing.T) {
+	b := t
+	tc := testCase(b, "error", source_error)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_error)
+}
+
+func TestGenerror_IP(t *testing.T) {
+	b := t
+	tc := testCase(b, "error", source_error)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_error)
+}
+
+func TestGenerror_VS(t *testing.T) {
+	b := t
+	tc := testCase(b, "error", source_error)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_error)
+}
+
+func TestGenerror_VP(t *testing.T) {
+	b := t
+	tc := testCase(b, "error", source_error)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_error)
 }
 
 // Test case func (tests/func.coa)
 //go:embed tests/func.coa
 var source_func string
 
+//go:embed tests/func.golden
+var golden_func []byte
+
 func BenchmarkGenfunc_IS(b *testing.B) {
 	tc := testCase(b, "func", source_func)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_func)
 }
 
 func BenchmarkGenfunc_IP(b *testing.B) {
 	tc := testCase(b, "func", source_func)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_func)
 }
 
 func BenchmarkGenfunc_VS(b *testing.B) {
 	tc := testCase(b, "func", source_func)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_func)
 }
 
 func BenchmarkGenfunc_VP(b *testing.B) {
 	tc := testCase(b, "func", source_func)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_func)
 }
 
 func TestGenfunc_IS(t *testing.T) {
 	b := t
 	tc := testCase(b, "func", source_func)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_func)
 }
 
 func TestGenfunc_IP(t *testing.T) {
 	b := t
 	tc := testCase(b, "func", source_func)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_func)
 }
 
 func TestGenfunc_VS(t *testing.T) {
 	b := t
 	tc := testCase(b, "func", source_func)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_func)
 }
 
 func TestGenfunc_VP(t *testing.T) {
 	b := t
 	tc := testCase(b, "func", source_func)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_func)
+}
+
+// Test case io (tests/io.coa)
+//go:embed tests/io.coa
+var source_io string
+
+//go:embed tests/io.golden
+var golden_io []byte
+
+func BenchmarkGenio_IS(b *testing.B) {
+	tc := testCase(b, "io", source_io)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_io)
+}
+
+func BenchmarkGenio_IP(b *testing.B) {
+	tc := testCase(b, "io", source_io)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_io)
+}
+
+func BenchmarkGenio_VS(b *testing.B) {
+	tc := testCase(b, "io", source_io)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_io)
+}
+
+func BenchmarkGenio_VP(b *testing.B) {
+	tc := testCase(b, "io", source_io)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_io)
+}
+
+func TestGenio_IS(t *testing.T) {
+	b := t
+	tc := testCase(b, "io", source_io)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_io)
+}
+
+func TestGenio_IP(t *testing.T) {
+	b := t
+	tc := testCase(b, "io", source_io)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_io)
+}
+
+func TestGenio_VS(t *testing.T) {
+	b := t
+	tc := testCase(b, "io", source_io)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_io)
+}
+
+func TestGenio_VP(t *testing.T) {
+	b := t
+	tc := testCase(b, "io", source_io)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_io)
 }
 
 // Test case loop (tests/loop.coa)
 //go:embed tests/loop.coa
 var source_loop string
 
+//go:embed tests/loop.golden
+var golden_loop []byte
+
 func BenchmarkGenloop_IS(b *testing.B) {
 	tc := testCase(b, "loop", source_loop)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_loop)
 }
 
 func BenchmarkGenloop_IP(b *testing.B) {
 	tc := testCase(b, "loop", source_loop)
-	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_loop)
 }
 
 func BenchmarkGenloop_VS(b *testing.B) {
 	tc := testCase(b, "loop", source_loop)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_loop)
 }
 
 func BenchmarkGenloop_VP(b *testing.B) {
 	tc := testCase(b, "loop", source_loop)
-	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_loop)
 }
 
 func TestGenloop_IS(t *testing.T) {
 	b := t
 	tc := testCase(b, "loop", source_loop)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_loop)
 }
 
 func TestGenloop_IP(t *testing.T) {
 	b := t
 	tc := testCase(b, "loop", source_loop)
-	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_loop)
 }
 
 func TestGenloop_VS(t *testing.T) {
 	b := t
 	tc := testCase(b, "loop", source_loop)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_loop)
 }
 
 func TestGenloop_VP(t *testing.T) {
 	b := t
 	tc := testCase(b, "loop", source_loop)
-	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true})
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_loop)
+}
+
+// Test case natives (tests/natives.coa)
+//go:embed tests/natives.coa
+var source_natives string
+
+//go:embed tests/natives.golden
+var golden_natives []byte
+
+func BenchmarkGennatives_IS(b *testing.B) {
+	tc := testCase(b, "natives", source_natives)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_natives)
+}
+
+func BenchmarkGennatives_IP(b *testing.B) {
+	tc := testCase(b, "natives", source_natives)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_natives)
+}
+
+func BenchmarkGennatives_VS(b *testing.B) {
+	tc := testCase(b, "natives", source_natives)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_natives)
+}
+
+func BenchmarkGennatives_VP(b *testing.B) {
+	tc := testCase(b, "natives", source_natives)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_natives)
+}
+
+func TestGennatives_IS(t *testing.T) {
+	b := t
+	tc := testCase(b, "natives", source_natives)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_natives)
+}
+
+func TestGennatives_IP(t *testing.T) {
+	b := t
+	tc := testCase(b, "natives", source_natives)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_natives)
+}
+
+func TestGennatives_VS(t *testing.T) {
+	b := t
+	tc := testCase(b, "natives", source_natives)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_natives)
+}
+
+func TestGennatives_VP(t *testing.T) {
+	b := t
+	tc := testCase(b, "natives", source_natives)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_natives)
+}
+
+// Test case parallel (tests/parallel.coa)
+//go:embed tests/parallel.coa
+var source_parallel string
+
+//go:embed tests/parallel.golden
+var golden_parallel []byte
+
+func BenchmarkGenparallel_IS(b *testing.B) {
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_parallel)
+}
+
+func BenchmarkGenparallel_IP(b *testing.B) {
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Run(b, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_parallel)
+}
+
+func BenchmarkGenparallel_VS(b *testing.B) {
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_parallel)
+}
+
+func BenchmarkGenparallel_VP(b *testing.B) {
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Run(b, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_parallel)
+}
+
+func TestGenparallel_IS(t *testing.T) {
+	b := t
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: false}, golden_parallel)
+}
+
+func TestGenparallel_IP(t *testing.T) {
+	b := t
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Test(t, TestCaseConfig{Engine: EngineInterp, Parallel: true}, golden_parallel)
+}
+
+func TestGenparallel_VS(t *testing.T) {
+	b := t
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: false}, golden_parallel)
+}
+
+func TestGenparallel_VP(t *testing.T) {
+	b := t
+	tc := testCase(b, "parallel", source_parallel)
+	tc.Test(t, TestCaseConfig{Engine: EngineVM, Parallel: true}, golden_parallel)
 }
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+const testPolicy = `# only data and one host
+allow fs.local ./data/*
+deny fs.local data/secret.txt
+allow http https://example.com/*
+allow io.*  # stdin and stdout
+`
+
+func TestPolicy(t *testing.T) {
+	p, err := parser.ParsePolicy("test.policy", strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name, arg string
+		want      bool
+	}{
+		{"fs.local", "data/a.txt", true},
+		{"fs.local", "./data/sub/a.txt", true},
+		{"fs.local", "data/secret.txt", false},
+		{"fs.local", "data/../secret.txt", false},
+		{"fs.local", "a.txt", false},
+		{"http", "https://example.com/a?b=c", true},
+		{"http", "https://example.org/", false},
+		{"io.stdout", "", true},
+		{"io.stdin", "", true},
+		{"os.exit", "", false},
+	}
+	for _, c := range cases {
+		r := util.Resource{Name: c.name, Arg: c.arg}
+		if got := p.Allowed(r); got != c.want {
+			t.Errorf("%s: want %t, got %t", r, c.want, got)
+		}
+	}
+}
+
+func TestPolicyErrors(t *testing.T) {
+	cases := []struct {
+		src, want string
+	}{
+		{"allow fs.local\npermit http", "p:2: wanted allow or deny, got permit"},
+		{"deny", "p:1: wanted allow|deny name [arg], got deny"},
+		{"allow fs.local [", "p:1: argument ["},
+	}
+	for _, c := range cases {
+		_, err := parser.ParsePolicy("p", strings.NewReader(c.src))
+		if err == nil || !strings.HasPrefix(err.Error(), c.want) {
+			t.Errorf("%q: want error %q, got %v", c.src, c.want, err)
+		}
+	}
+}
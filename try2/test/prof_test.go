@@ -0,0 +1,58 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/prof"
+)
+
+func TestProfile(t *testing.T) {
+	tc := testCase(t, "prof", "(@def sq {(@mul $0 $0)})\n(@map (@range 10) {(sq $1)})")
+	for _, cfg := range TestCaseConfigs {
+		t.Run(cfg.String(), func(t *testing.T) {
+			cfg.Profile = prof.New()
+			_, err := tc.Eval(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := map[string]int64{}
+			for _, s := range cfg.Profile.Sites() {
+				if s.Pos.Line == 0 {
+					t.Errorf("%s: no position", s.Site)
+				}
+				if s.Inclusive < s.Exclusive {
+					t.Errorf("%s: inclusive %s less than exclusive %s", s.Site, s.Inclusive, s.Exclusive)
+				}
+				calls[s.Name] += s.Calls
+			}
+			for name, want := range map[string]int64{"sq": 10, "@mul": 10, "@map": 1} {
+				if calls[name] != want {
+					t.Errorf("%s: want %d call(s), got %d", name, want, calls[name])
+				}
+			}
+
+			b := new(bytes.Buffer)
+			err = cfg.Profile.WritePprof(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := gzip.NewReader(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []string{"sq", "@mul", "prof", "nanoseconds"} {
+				if !strings.Contains(string(data), s) {
+					t.Errorf("profile does not contain %s", s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/repl"
+)
+
+func TestDepth(t *testing.T) {
+	cases := []struct {
+		src  string
+		want int
+	}{
+		{"(@add 1 2)\n", 0},
+		{"(@def f {\n", 2},
+		{"[1 (2\n", 2},
+		{"(@add 1 2))\n", -1},
+		{"(@io_outln \"(\"\n", 1},
+		{"(@io_outln \"a\\\")\n", 2},
+		{"(@io_outln '(')\n", 0},
+		{"(@io_outln \"a\n", 2},
+		{"(@add 1 2) # (\n", 0},
+		{"# (\n(\n", 1},
+	}
+	for _, c := range cases {
+		if got := repl.Depth(c.src); got != c.want {
+			t.Errorf("%q: want %d, got %d", c.src, c.want, got)
+		}
+	}
+}
+
+func TestREPL(t *testing.T) {
+	cases := []struct {
+		name, in string
+		want     []string
+	}{
+		{"eval", "(@add 1 2)\n", []string{"coa> 3\n"}},
+		{"continued", "(@add 1\n2)\n", []string{"coa> ...> 3\n"}},
+		{"defined", "(@def x 2)\n(@mul x 3)\n", []string{"6\n"}},
+		{"stdout", "(@io_out \"hi\")\n", []string{"hi2\n"}},
+		{"error", "(@len 1)\n", []string{"error: "}},
+		{"dump", "(@def x 2)\n:dump\n", []string{"x"}},
+		{"peek", ":peek (@def y x)\n", []string{"0:\n\tuses: [x]\n\tsets: [y]\n"}},
+		{"load", ":load tests/add.coa\n", []string{"coa> 3\n"}},
+		{"load without path", ":load\n", []string{"error: usage: :load path\n"}},
+		{"help", ":help\n", []string{repl.Help}},
+		{"unknown", ":nope\n", []string{"error: unknown command :nope (see :help)\n"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			r := repl.New(parser.NewEnv(lexer.Position{Filename: "repl"}, false), out)
+			err := r.Run(strings.NewReader(c.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range c.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("want %q in output:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/errs"
+)
+
+const reportSrc = "(@def f {\n\t(@assert @false \"inner\")\n})\n(f)"
+
+func TestReport(t *testing.T) {
+	tc := testCase(t, "report.coa", reportSrc)
+	for _, cfg := range TestCaseConfigs {
+		t.Run(cfg.String(), func(t *testing.T) {
+			_, err := tc.Eval(cfg)
+			if err == nil {
+				t.Fatal("want error")
+			}
+			r := &errs.Renderer{ReadFile: func(filename string) ([]byte, error) {
+				if filename != "report.coa" {
+					return nil, errors.New("not found")
+				}
+				return []byte(reportSrc), nil
+			}}
+			b := new(bytes.Buffer)
+			err = r.Render(b, err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := " --> report.coa:2:2\n  |\n2 | \t(@assert @false \"inner\")\n  | \t^\n"
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("want %q in:\n%s", want, b)
+			}
+			if !strings.Contains(b.String(), "4 | (f)\n  | ^\n") {
+				t.Errorf("want the caller in:\n%s", b)
+			}
+			if strings.Contains(b.String(), "\x1b[") {
+				t.Errorf("want no colors in:\n%s", b)
+			}
+		})
+	}
+}
+
+func TestReportCollapse(t *testing.T) {
+	frame := func(line int, call string) errs.ERTFrame {
+		return errs.ERTFrame{Pos: lexer.Position{Filename: "rec.coa", Line: line, Column: 1}, Call: call}
+	}
+	var err error = errors.New("deep")
+	err = errs.AppendERT(err, frame(1, "(@assert)"))
+	for i := 0; i < 50; i++ {
+		err = errs.AppendERT(err, frame(2, "(g)"))
+		err = errs.AppendERT(err, frame(3, "(f)"))
+	}
+	err = errs.AppendERT(err, frame(4, "(f)"))
+
+	r := errs.NewReport(err)
+	if r.Message != "deep" {
+		t.Errorf("message: want deep, got %s", r.Message)
+	}
+	lines := make([]int, len(r.Frames))
+	for i, f := range r.Frames {
+		lines[i] = f.Line
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("lines: want %v, got %v", want, lines)
+	}
+	if f := r.Frames[2]; f.Repeat != 49 || f.Cycle != 2 {
+		t.Errorf("want last 2 frames repeated 49 times, got %d times %d", f.Repeat, f.Cycle)
+	}
+
+	b := new(bytes.Buffer)
+	err = errs.WriteJSON(b, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Errors []errs.Report `json:"errors"`
+	}
+	err = json.Unmarshal(b.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Errors) != 1 || len(decoded.Errors[0].Frames) != 4 || decoded.Errors[0].Frames[2].Repeat != 49 {
+		t.Errorf("unexpected JSON %s", b)
+	}
+}
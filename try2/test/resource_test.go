@@ -0,0 +1,82 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
+)
+
+func TestResourceDenied(t *testing.T) {
+	guard, err := parser.ParsePolicy("test.policy", strings.NewReader("allow fs.local tests/*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name, src string
+		want      *util.Resource
+		line      int
+	}{
+		{"allowed", `(@file_read "tests/add.coa")`, nil, 0},
+		{"denied", `(@file_read "secret.txt")`, &util.Resource{Name: "fs.local", Arg: "secret.txt"}, 1},
+		{"outside", "(@def a \"tests/../secret.txt\")\n(@file_read a)", &util.Resource{Name: "fs.local", Arg: "tests/../secret.txt"}, 2},
+		{"inner env", "(@def f {(@file_remove $0)})\n(f \"tests/../missing.txt\")", &util.Resource{Name: "fs.local", Arg: "tests/../missing.txt"}, 1},
+		{"no argument", `(@time_now)`, &util.Resource{Name: "os.time"}, 1},
+		// the position of calls by natives is not known
+		{"by native", `(@mapnokey ["secret.txt"] @file_read)`, &util.Resource{Name: "fs.local", Arg: "secret.txt"}, 0},
+	}
+	for _, engine := range []Engine{EngineInterp, EngineVM} {
+		for _, c := range cases {
+			t.Run(fmt.Sprintf("%s %d", c.name, engine), func(t *testing.T) {
+				tc := testCase(t, c.name, c.src)
+				_, err := tc.Eval(TestCaseConfig{Engine: engine, Guard: guard})
+				if c.want == nil {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				var denied *parser.ResourceDeniedError
+				if !errors.As(err, &denied) {
+					t.Fatalf("want resource denied error, got %v", err)
+				}
+				if denied.Resource != *c.want {
+					t.Errorf("resource: want %s, got %s", c.want, denied.Resource)
+				}
+				if c.line != 0 && denied.Pos.Line != c.line {
+					t.Errorf("line: want %d, got %s", c.line, denied.Pos)
+				}
+			})
+		}
+	}
+}
+
+type countGuard struct {
+	lock  sync.Mutex
+	count int
+}
+
+func (g *countGuard) Allowed(util.Resource) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.count++
+	return true
+}
+
+func TestResourceGuardedOnce(t *testing.T) {
+	tc := testCase(t, "once", `(@time_now)`)
+	for _, engine := range []Engine{EngineInterp, EngineVM} {
+		guard := new(countGuard)
+		_, err := tc.Eval(TestCaseConfig{Engine: engine, Guard: guard})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if guard.count != 1 {
+			t.Errorf("engine %d: want 1 check, got %d", engine, guard.count)
+		}
+	}
+}
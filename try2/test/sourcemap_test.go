@@ -0,0 +1,77 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/compile"
+	"gitlab.com/coalang/go-coa/try2/encode"
+	"gitlab.com/coalang/go-coa/try2/vm"
+)
+
+func TestSourceMap(t *testing.T) {
+	tc := testCase(t, "sourcemap", "(@def a 1)\n(@def f {\n\t(@add a $0)\n})\n(f [2])")
+	ce := compile.NewEnv(lexer.Position{Filename: "root"})
+	insts, err := ce.NewScope().CompileNodes(*tc.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	is := compile.Instructions(insts)
+	m := is.SourceMap()
+	if len(m.Runs) >= len(is) {
+		t.Errorf("want fewer runs than instructions, got %d runs for %d", len(m.Runs), len(is))
+	}
+	found := false
+	for offset, inst := range is {
+		pos, ok := is.Pos(offset)
+		if !ok {
+			t.Fatalf("+%03x %s: no position", offset, &inst)
+		}
+		if pos2, _ := m.Pos(offset); pos2 != pos {
+			t.Errorf("+%03x: source map has %s, want %s", offset, pos2, pos)
+		}
+		if inst.Opcode == compile.OpArgLoad {
+			found = true
+			if pos.Line != 3 || pos.Column != 10 {
+				t.Errorf("$0: want 3:10, got %s", pos)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("no OpArgLoad")
+	}
+
+	b := new(bytes.Buffer)
+	err = encode.Encode(b, &encode.Program{Env: ce, Insts: is})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := encode.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for offset := range is {
+		want, _ := is.Pos(offset)
+		if got, ok := p.Insts.Pos(offset); !ok || got != want {
+			t.Errorf("+%03x: decoded %s, want %s", offset, got, want)
+		}
+	}
+}
+
+func TestVMErrorSource(t *testing.T) {
+	tc := testCase(t, "source", "(@def f {\n\t(@assert @false \"inner\")\n})\n(f)")
+	_, err := tc.Eval(TestCaseConfig{Engine: EngineVM})
+	var et *vm.ErrorWithTrace
+	if !errors.As(err, &et) {
+		t.Fatalf("want *vm.ErrorWithTrace, got %v", err)
+	}
+	pos, ok := et.Source()
+	if !ok {
+		t.Fatal("no source position")
+	}
+	if pos.Filename != "source" || pos.Line != 2 {
+		t.Errorf("want error at source:2, got %s", pos)
+	}
+}
@@ -0,0 +1,29 @@
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+func TestStdio(t *testing.T) {
+	tc := testCase(t, "stdio", "(@def a (@io_in '\\n'))\n(@def b (@concat a (@io_in '\\n')))\n(@io_errln b)")
+	for _, cfg := range TestCaseConfigs {
+		t.Run(cfg.String(), func(t *testing.T) {
+			stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+			cfg.Stdio = &parser.Stdio{In: strings.NewReader("one\ntwo\n"), Out: stdout, Err: stderr}
+			_, err := tc.Eval(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stderr.String(); got != "onetwo\n" {
+				t.Errorf("stderr: want %q, got %q", "onetwo\n", got)
+			}
+			if got := stdout.String(); got != "" {
+				t.Errorf("stdout: want nothing, got %q", got)
+			}
+		})
+	}
+}
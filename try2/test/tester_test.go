@@ -0,0 +1,75 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+type recordTester struct {
+	lock    sync.Mutex
+	skip    string
+	results map[string]error
+}
+
+func (r *recordTester) Test(name string, _ lexer.Position, run func() error) bool {
+	if name == r.skip {
+		return true
+	}
+	err := run()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.results[name] = err
+	return err == nil
+}
+
+func TestTester(t *testing.T) {
+	src := `(@def helper 1)
+(@test "passes" {(@assert (@eq (@add 1 2) 3) "sum")})
+(@test "fails" {
+	(@assert @false "false")
+})
+(@test "lone" {helper})
+(@test "skipped" {(@assert @false "skipped")})
+`
+	tc := testCase(t, "tester", src)
+	for _, parallel := range []bool{false, true} {
+		r := &recordTester{skip: "skipped", results: map[string]error{}}
+		env := parser.NewEnv(lexer.Position{Filename: "root"}, parallel)
+		env.Tester = r
+		_, err := tc.root.Eval(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.results) != 3 {
+			t.Fatalf("want 3 tests run, got %v", r.results)
+		}
+		if err := r.results["passes"]; err != nil {
+			t.Errorf("passes: %s", err)
+		}
+		var ert *errs.ERT
+		if err := r.results["fails"]; !errors.As(err, &ert) {
+			t.Errorf("fails: want *errs.ERT, got %v", err)
+		} else if pos := ert.Frames()[0].Pos; pos.Line != 4 {
+			t.Errorf("fails: want line 4, got %s", pos)
+		}
+		if err := r.results["lone"]; err == nil || !strings.Contains(err.Error(), "helper not found") {
+			t.Errorf("lone: want helper not found, got %v", err)
+		}
+	}
+}
+
+func TestTesterNone(t *testing.T) {
+	tc := testCase(t, "tester", `(@test "fails" {(@assert @false "false")})`)
+	for _, cfg := range TestCaseConfigs {
+		_, err := tc.Eval(cfg)
+		if err == nil || !strings.Contains(err.Error(), "test fails failed") {
+			t.Errorf("%s: want test failure, got %v", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/trace"
+)
+
+func TestTrace(t *testing.T) {
+	tc := testCase(t, "trace", "(@def a (@add 1 2))\n(@def b (@add 3 4))\n(@add a b)")
+	for _, engine := range []Engine{EngineInterp, EngineVM} {
+		cfg := TestCaseConfig{Engine: engine, Parallel: true, Tracer: trace.New()}
+		t.Run(cfg.String(), func(t *testing.T) {
+			_, err := tc.Eval(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			strands := 0
+			for _, e := range cfg.Tracer.Events() {
+				t.Logf("%+v", e)
+				if e.Ph == "X" && strings.HasPrefix(e.Name, "strand ") {
+					strands++
+				}
+			}
+			if strands < 2 {
+				t.Errorf("want at least 2 strands, got %d", strands)
+			}
+		})
+	}
+
+	cfg := TestCaseConfig{Engine: EngineInterp, Parallel: true, Tracer: trace.New()}
+	_, err := tc.Eval(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := new(bytes.Buffer)
+	err = cfg.Tracer.WriteJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		TraceEvents []map[string]interface{} `json:"traceEvents"`
+	}
+	err = json.Unmarshal(b.Bytes(), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.TraceEvents) == 0 {
+		t.Error("no events")
+	}
+}
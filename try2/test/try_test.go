@@ -0,0 +1,30 @@
+package test
+
+import "testing"
+
+func TestTry(t *testing.T) {
+	cases := []struct{ name, src string }{
+		{"value", `(@assert (@eq (@try {1} {2}) 1) "value")`},
+		{"error", `(@assert (@eq (@try {(@error "boom")} {(@get $0 "message")}) "boom") "message")`},
+		{"kind", `(@assert (@try {(@error "boom")} {(@error_is $0 "error")}) "error")
+(@assert (@try {(@assert @false "no")} {(@error_is $0 "assert")}) "assert")
+(@assert (@try {(@lt (@complex 1 1) 1)} {(@error_is $0 "undefined")}) "undefined")
+(@assert (@try {(@add 1 "a")} {(@error_is $0 "native")}) "native")
+(@assert (@try {(@try {(@error "boom")} {(@get $0 "nope")})} {(@error_is $0 "native")}) "rethrown")`},
+		{"frames", `(@def f {(@error "deep")})
+(@assert (@try {(f)} {(@ne (@get $0 "frames") [])}) "frames")
+(@assert (@try {(f)} {(@eq (@get $0 "pos") "frames:1:10")}) "pos")`},
+		{"break", `(@while @true {(@try {(@break)} {(@error "caught @break")})})`},
+	}
+	for _, c := range cases {
+		tc := testCase(t, c.name, c.src)
+		for _, cfg := range TestCaseConfigs {
+			t.Run(c.name+" "+cfg.String(), func(t *testing.T) {
+				_, err := tc.Eval(cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+func typeCheck(t *testing.T, name, src string) []*parser.TypeError {
+	root := &parser.Nodes{}
+	err := parser.Parser.ParseString(name, src, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = parser.TypeCheck(root)
+	if err == nil {
+		return nil
+	}
+	var errs2 errs.Errors
+	if !errors.As(err, &errs2) {
+		t.Fatalf("unexpected error %s", err)
+	}
+	re := make([]*parser.TypeError, len(errs2))
+	for i, err := range errs2 {
+		if !errors.As(err, &re[i]) {
+			t.Fatalf("unexpected error %s", err)
+		}
+	}
+	return re
+}
+
+func TestTypeCheck(t *testing.T) {
+	cases := []struct {
+		name, src string
+		want      []string
+	}{
+		{"ok", "(@def f {(@add $0 1)})\n(@map [1 2] f)\n(@io_outln (@string (@len [1 2])))", nil},
+		{"callable", "(@map [1 2] 3)", []string{"1:13: @map: argument 2: wanted Callable, got Number"}},
+		{"def", "(@def f 1)\n(@len f)", []string{"2:7: @len: argument 1: wanted HasNodes, got Number"}},
+		{"length", "(@len [1] [2])", []string{"1:1: @len: wanted (HasNodes), got 2 argument(s)"}},
+		{"length any of", "(@concat \"a\" \"b\" \"c\")", []string{"1:1: @concat: wanted (nil|Number|BecomesString nil|Number|BecomesString), got 3 argument(s)"}},
+		{"not callable", "(@def n 1)\n(n 2)", []string{"2:1: n: cannot call Number"}},
+		{"mod", "(@def x 1)\n(@mod x \"a\")\n(@len x)", nil},
+		{"special", "(@def f {1})\n(@while @true f)", []string{"2:15: @while: argument 2: wanted Callable, got ID"}},
+		{"all", "(@len 1)\n{(@map 1 {})}", []string{
+			"1:7: @len: argument 1: wanted HasNodes, got Number",
+			"2:8: @map: argument 1: wanted Iter, got Number",
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := typeCheck(t, c.name, c.src)
+			if len(got) != len(c.want) {
+				t.Fatalf("want %d errors, got %v", len(c.want), got)
+			}
+			for i, err := range got {
+				if want := c.name + ":" + c.want[i]; err.Error() != want {
+					t.Errorf("want %q, got %q", want, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestTypeCheckTests(t *testing.T) {
+	paths, err := filepath.Glob("tests/*.coa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if filepath.Base(path) == "loop.coa" {
+			// calls @io_outln with a block, which TypeCheck rightly reports
+			continue
+		}
+		t.Run(path, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if errs2 := typeCheck(t, path, string(src)); len(errs2) != 0 {
+				t.Fatal(errs2)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/compile"
+	"gitlab.com/coalang/go-coa/try2/vm"
+)
+
+func TestVMErrors(t *testing.T) {
+	pos := compile.Instruction{Opcode: compile.OpPos, B: "bad.coa:2:3"}
+	cases := []struct {
+		name  string
+		insts []compile.Instruction
+		want  string
+	}{
+		{"unknown opcode", []compile.Instruction{pos, {Opcode: 0xfe}}, "unknown opcode"},
+		{"stack underflow", []compile.Instruction{pos, {Opcode: compile.OpPop, A: 1}}, "stack underflow"},
+		{"block end", []compile.Instruction{pos, {Opcode: compile.OpBlockEnd}}, "block end without block start"},
+		{"scope level", []compile.Instruction{pos, {Opcode: compile.OpVarLoad, A: 0, B: 3}}, "scope level 3 out of range"},
+		{"variable", []compile.Instruction{pos, {Opcode: compile.OpVarDeclare, A: 1}, {Opcode: compile.OpVarLoad, A: 2, B: 0}}, "variable 2 on level 0 out of range"},
+		{"argument", []compile.Instruction{pos, {Opcode: compile.OpArgLoad, A: 0}}, "argument $0 not given"},
+		{"operand", []compile.Instruction{pos, {Opcode: compile.OpLitNumber, B: "1"}}, "invalid operand"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := vm.NewVM().Execute(vm.NewProgram(c.insts))
+			var et *vm.ErrorWithTrace
+			if !errors.As(err, &et) {
+				t.Fatalf("want *vm.ErrorWithTrace, got %v", err)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Errorf("want %q in %q", c.want, err)
+			}
+			if want := (lexer.Position{Filename: "bad.coa", Line: 2, Column: 3}); et.Pos() != want {
+				t.Errorf("pos: want %s, got %s", want, et.Pos())
+			}
+			if loc := et.Loc(); loc != len(c.insts)-1 {
+				t.Errorf("loc: want %d, got %d", len(c.insts)-1, loc)
+			}
+		})
+	}
+}
+
+func TestVMErrorTrace(t *testing.T) {
+	tc := testCase(t, "trace", "(@def f {\n\t(@assert @false \"inner\")\n})\n(f)")
+	_, err := tc.Eval(TestCaseConfig{Engine: EngineVM})
+	var et *vm.ErrorWithTrace
+	if !errors.As(err, &et) {
+		t.Fatalf("want *vm.ErrorWithTrace, got %v", err)
+	}
+	if pos := et.Pos(); pos.Line != 2 {
+		t.Errorf("want error on line 2, got %s", pos)
+	}
+	notes := make([]string, len(et.Trace()))
+	for i, f := range et.Trace() {
+		notes[i] = f.Note
+	}
+	if want := "Execute VMCall"; strings.Join(notes, " ") != want {
+		t.Errorf("notes: want %s, got %s", want, notes)
+	}
+}
+
+func TestCompileParallelUndefined(t *testing.T) {
+	// f is not defined yet when the block is compiled
+	tc := testCase(t, "undefined", "(@def f {(@if (@eq $0 0) {(@error \"x\")} {(f (@sub $0 1))})})\n(f 6)")
+	_, err := tc.Eval(TestCaseConfig{Engine: EngineVM, Parallel: true})
+	if err == nil || !strings.Contains(err.Error(), "required variable not defined: f") {
+		t.Fatalf("want f not defined, got %v", err)
+	}
+}
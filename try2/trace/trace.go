@@ -0,0 +1,131 @@
+// Package trace records how evaluation is scheduled, in the Chrome trace event
+// format (see chrome://tracing or https://ui.perfetto.dev).
+//
+// Each goroutine evaluating is a track (a thread in the trace), and spans on a
+// track are the strands, nodes and resource lock waits evaluated on it.
+// Flows connect strands to the strands waiting on them.
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Track identifies a goroutine in a trace.
+// The zero Track is the goroutine which started evaluating.
+type Track int64
+
+// Event is a trace event.
+// TS and Dur are in microseconds, TS since the start of the Tracer.
+type Event struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	TS   float64                `json:"ts"`
+	Dur  float64                `json:"dur,omitempty"`
+	PID  int                    `json:"pid"`
+	TID  Track                  `json:"tid"`
+	ID   int64                  `json:"id,omitempty"`
+	BP   string                 `json:"bp,omitempty"`
+	S    string                 `json:"s,omitempty"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// Tracer records events.
+// A nil *Tracer records nothing.
+type Tracer struct {
+	tracks int64
+	flows  int64
+	// tracks and flows are first to be aligned for atomic.
+	start time.Time
+
+	lock   sync.Mutex
+	events []Event
+}
+
+// New returns a Tracer starting now.
+func New() *Tracer {
+	return &Tracer{start: time.Now()}
+}
+
+// NewTrack returns a new Track named name.
+func (t *Tracer) NewTrack(name string) Track {
+	if t == nil {
+		return 0
+	}
+	track := Track(atomic.AddInt64(&t.tracks, 1))
+	t.add(Event{Name: "thread_name", Ph: "M", TID: track, Args: map[string]interface{}{"name": name}})
+	return track
+}
+
+// Span records a span on track from start until now.
+func (t *Tracer) Span(track Track, cat, name string, start time.Time, args map[string]interface{}) {
+	if t == nil {
+		return
+	}
+	t.add(Event{
+		Name: name,
+		Cat:  cat,
+		Ph:   "X",
+		TS:   t.since(start),
+		Dur:  float64(time.Since(start).Nanoseconds()) / 1e3,
+		TID:  track,
+		Args: args,
+	})
+}
+
+// Instant records an event on track happening now.
+func (t *Tracer) Instant(track Track, cat, name string, args map[string]interface{}) {
+	if t == nil {
+		return
+	}
+	t.add(Event{Name: name, Cat: cat, Ph: "i", S: "t", TS: t.since(time.Now()), TID: track, Args: args})
+}
+
+// FlowStart records the start of a flow on track now, and returns its ID for
+// FlowEnd.
+func (t *Tracer) FlowStart(track Track, cat, name string) int64 {
+	if t == nil {
+		return 0
+	}
+	id := atomic.AddInt64(&t.flows, 1)
+	t.add(Event{Name: name, Cat: cat, Ph: "s", TS: t.since(time.Now()), TID: track, ID: id})
+	return id
+}
+
+// FlowEnd records the end of the flow id on track now, which is bound to the
+// next span starting on track.
+func (t *Tracer) FlowEnd(track Track, cat, name string, id int64) {
+	if t == nil {
+		return
+	}
+	t.add(Event{Name: name, Cat: cat, Ph: "f", BP: "e", TS: t.since(time.Now()), TID: track, ID: id})
+}
+
+// Events returns the events recorded so far.
+func (t *Tracer) Events() []Event {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]Event(nil), t.events...)
+}
+
+// WriteJSON writes the events recorded so far to w in the JSON object format.
+func (t *Tracer) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []Event `json:"traceEvents"`
+		DisplayTimeUnit string  `json:"displayTimeUnit"`
+	}{t.Events(), "ms"})
+}
+
+func (t *Tracer) since(ts time.Time) float64 {
+	return float64(ts.Sub(t.start).Nanoseconds()) / 1e3
+}
+
+func (t *Tracer) add(e Event) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.events = append(t.events, e)
+}
@@ -93,10 +93,11 @@ func EvalResources2(rs []ResourceDef, args []string) []Resource {
 	for i, r := range rs {
 		re[i].Name = r.Name
 		if r.Arg != -1 {
-			if r.Arg >= len(re) {
+			if r.Arg >= len(args) {
 				re[i].Arg = "error"
+			} else {
+				re[i].Arg = args[r.Arg]
 			}
-			re[i].Arg = args[r.Arg]
 		}
 	}
 	return re
@@ -108,10 +109,11 @@ func EvalResources(rs []ResourceDef, args []string) ([]ResourceDef, []string) {
 		if r.Arg == -1 {
 			re[i] = ""
 		} else {
-			if r.Arg >= len(re) {
+			if r.Arg >= len(args) {
 				re[i] = "error"
+			} else {
+				re[i] = args[r.Arg]
 			}
-			re[i] = args[r.Arg]
 		}
 	}
 	return rs, re
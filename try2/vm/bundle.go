@@ -0,0 +1,262 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/coalang/go-coa/try2/compile"
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/trace"
+)
+
+// bundle is a parsed OpBundleStart (see compile.Scope.compileBundle).
+type bundle struct {
+	strands []*strand
+	nodes   int
+	end     int
+	// end is the location of the OpBundleEnd.
+}
+
+type strand struct {
+	todo        []int
+	reverseDeps []int
+	deps        int32
+	prog        *Program
+}
+
+// parseBundle parses the bundle starting at the OpBundleStart at start.
+func parseBundle(p *Program, start int) (*bundle, error) {
+	b := &bundle{end: start + p.insts[start].A}
+	if b.end >= len(p.insts) || p.insts[b.end].Opcode != compile.OpBundleEnd {
+		return nil, errors.New("bundle end not found")
+	}
+	b.nodes = p.insts[b.end].A
+	for i := start + 1; i < b.end; {
+		if p.insts[i].Opcode != compile.OpStrandStart {
+			return nil, fmt.Errorf("+%03x: expected strand start", p.offset+i)
+		}
+		end := i + p.insts[i].A
+		s := new(strand)
+		j := i + 1
+		for ; p.insts[j].Opcode == compile.OpStrandTodo; j++ {
+			s.todo = append(s.todo, p.insts[j].A)
+		}
+		if p.insts[j].Opcode != compile.OpStrandReverseDeps {
+			return nil, fmt.Errorf("+%03x: expected strand reverse deps", p.offset+j)
+		}
+		n := p.insts[j].A
+		for k := 1; k <= n; k++ {
+			s.reverseDeps = append(s.reverseDeps, p.insts[j+k].A)
+		}
+		j += n + 1
+		s.prog = &Program{offset: p.offset + j, insts: p.insts[j:end]}
+		b.strands = append(b.strands, s)
+		i = end + 1
+	}
+	for _, s := range b.strands {
+		for _, rd := range s.reverseDeps {
+			b.strands[rd].deps++
+		}
+	}
+	return b, nil
+}
+
+// runBundle runs each strand of b in its own goroutine once the strands it depends
+// on are done, and returns the values of the nodes in order.
+// Strands depending on a failed strand are not run.
+// If v is deterministic, the strands are run one at a time instead.
+func (v *VM) runBundle(b *bundle) ([]Value, error) {
+	if v.Deterministic != nil {
+		return v.runBundleDeterministic(b)
+	}
+	start := time.Now()
+	values := make([]Value, b.nodes)
+	var wg sync.WaitGroup
+	var errsLock sync.Mutex
+	var errs2 errs.Errors
+	var varsLock sync.Mutex
+	// varsLock locks the variables of the current scope while forking and joining
+	var flowsLock sync.Mutex
+	flows := make([][]int64, len(b.strands))
+	// flows are the flows from the strands each strand depends on, for Tracer
+	var run func(i int, track trace.Track)
+	run = func(i int, track trace.Track) {
+		defer wg.Done()
+		s := b.strands[i]
+		if v.Tracer != nil {
+			// all deps are done, so flows[i] is not modified anymore
+			for _, flow := range flows[i] {
+				v.Tracer.FlowEnd(track, "parallel", "dep", flow)
+			}
+		}
+		strandStart := time.Now()
+		varsLock.Lock()
+		f := v.fork("strand")
+		varsLock.Unlock()
+		f.track = track
+		err := f.exec(s.prog)
+		varsLock.Lock()
+		v.join(f)
+		varsLock.Unlock()
+		if v.Tracer != nil {
+			v.Tracer.Span(track, "parallel", fmt.Sprintf("strand %d", i), strandStart, map[string]interface{}{
+				"pos":         v.s().pos,
+				"todo":        s.todo,
+				"reverseDeps": s.reverseDeps,
+			})
+		}
+		if err != nil {
+			errsLock.Lock()
+			errs2 = append(errs2, err)
+			errsLock.Unlock()
+			return
+		}
+		for i, node := range s.todo {
+			values[node] = f.s().stack[i]
+		}
+		for _, rd := range s.reverseDeps {
+			if v.Tracer != nil {
+				flowsLock.Lock()
+				flows[rd] = append(flows[rd], v.Tracer.FlowStart(track, "parallel", "dep"))
+				flowsLock.Unlock()
+			}
+			if atomic.AddInt32(&b.strands[rd].deps, -1) == 0 {
+				wg.Add(1)
+				go run(rd, v.newTrack())
+			}
+		}
+	}
+	starts := make([]int, 0, len(b.strands))
+	for i, s := range b.strands {
+		if s.deps == 0 {
+			starts = append(starts, i)
+		}
+	}
+	wg.Add(len(starts))
+	for _, i := range starts {
+		go run(i, v.newTrack())
+	}
+	wg.Wait()
+	if v.Tracer != nil {
+		v.Tracer.Span(v.track, "parallel", "wait strands", start, map[string]interface{}{
+			"pos":     v.s().pos,
+			"strands": len(b.strands),
+		})
+	}
+	switch len(errs2) {
+	case 0:
+		return values, nil
+	case 1:
+		return nil, errs2[0]
+	default:
+		return nil, errs2
+	}
+}
+
+// newTrack returns a track for a goroutine running strands of the current scope.
+func (v *VM) newTrack() trace.Track {
+	if v.Tracer == nil {
+		return 0
+	}
+	return v.Tracer.NewTrack("strands of " + v.s().pos)
+}
+
+// runBundleDeterministic runs the strands of b one at a time in the current
+// goroutine, in the order picked by v.Deterministic.
+func (v *VM) runBundleDeterministic(b *bundle) ([]Value, error) {
+	values := make([]Value, b.nodes)
+	var errs2 errs.Errors
+	deps := make([]int, len(b.strands))
+	for i, s := range b.strands {
+		deps[i] = int(s.deps)
+	}
+	v.Deterministic.Run(deps, func(i int) []int {
+		s := b.strands[i]
+		start := time.Now()
+		f := v.fork("strand")
+		err := f.exec(s.prog)
+		v.join(f)
+		if v.Tracer != nil {
+			v.Tracer.Span(v.track, "parallel", fmt.Sprintf("strand %d", i), start, map[string]interface{}{
+				"pos":         v.s().pos,
+				"todo":        s.todo,
+				"reverseDeps": s.reverseDeps,
+			})
+		}
+		if err != nil {
+			errs2 = append(errs2, err)
+			return nil
+		}
+		for i, node := range s.todo {
+			values[node] = f.s().stack[i]
+		}
+		return s.reverseDeps
+	})
+	switch len(errs2) {
+	case 0:
+		return values, nil
+	case 1:
+		return nil, errs2[0]
+	default:
+		return nil, errs2
+	}
+}
+
+// fork returns a VM to run a strand of the current scope in.
+// The VM shares the scopes and resources of v, but the current scope has its own
+// stack and a copy of the variables, which are merged back by join.
+func (v *VM) fork(note string) *VM {
+	f := &VM{
+		scopes:         append(make([]*Scope, 0, len(v.scopes)), v.scopes...),
+		globalProg:     v.globalProg,
+		ResourcesGuard: v.ResourcesGuard,
+		resources:      v.resources,
+		resourceLock:   v.resourceLock,
+		ctx:            v.ctx,
+		Limits:         v.Limits,
+		Stdio:          v.Stdio,
+		Profile:        v.Profile,
+		Deterministic:  v.Deterministic,
+		Debugger:       v.Debugger,
+		Tracer:         v.Tracer,
+		track:          v.track,
+		frame:          v.frame,
+		steps:          v.steps,
+	}
+	s := *v.s()
+	s.stack = nil
+	s.regions = nil
+	s.vars = append([]Value(nil), s.vars...)
+	s.varNames = append([]string(nil), s.varNames...)
+	s.vm = f
+	s.note = note
+	f.scopes[len(f.scopes)-1] = &s
+	f.forked = append([]Value(nil), s.vars...)
+	return f
+}
+
+// join merges the variables of the current scope of f, forked from v, back into
+// the current scope of v.
+// Only the variables f assigned are merged, so that strands assigning different
+// variables do not undo each other. Variables f defined by name (see iEnv.Def)
+// are added.
+func (v *VM) join(f *VM) {
+	s, fs := v.s(), f.s()
+	for i, value := range fs.vars {
+		switch {
+		case i >= len(f.forked):
+			if j := s.varIndex(fs.varNames[i]); j != -1 {
+				s.vars[j] = value
+			} else {
+				s.vars = append(s.vars, value)
+				s.varNames = append(s.varNames, fs.varNames[i])
+			}
+		case value != f.forked[i] && i < len(s.vars):
+			s.vars[i] = value
+			s.varNames[i] = fs.varNames[i]
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"errors"
+
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// region is a loop or an inlined body started by OpLoopStart or OpBodyStart.
+type region struct {
+	loop bool
+
+	end int
+	// end is the location of the OpLoopEnd or OpBodyEnd.
+
+	cont int
+	// cont is the location OpContinue jumps to (loops only).
+
+	height int
+	// height is the height of the stack when the region started.
+}
+
+// control handles err (parser.ErrBreak, parser.ErrContinue or *parser.ErrReturn)
+// using the regions of s, and returns the location to continue execution at.
+// err2 is non-nil if err must be returned from the current program instead.
+func (s *Scope) control(err error) (to int, err2 error) {
+	var ret *parser.ErrReturn
+	switch {
+	case errors.Is(err, parser.ErrBreak), errors.Is(err, parser.ErrContinue):
+		for i := len(s.regions) - 1; i >= 0; i-- {
+			r := s.regions[i]
+			if !r.loop {
+				continue
+			}
+			s.regions = s.regions[:i+1]
+			s.stack = s.stack[:r.height]
+			if errors.Is(err, parser.ErrBreak) {
+				return r.end, nil
+			}
+			return r.cont, nil
+		}
+		return 0, err
+	case errors.As(err, &ret):
+		n := ret.Len
+		for i := len(s.regions) - 1; i >= 0; i-- {
+			r := s.regions[i]
+			if r.loop {
+				continue
+			}
+			if n <= 1 {
+				s.regions = s.regions[:i+1]
+				s.stack = append(s.stack[:r.height], &valueProxy{ret.Value})
+				return r.end, nil
+			}
+			n--
+		}
+		return 0, &parser.ErrReturn{Len: n, Value: ret.Value}
+	default:
+		return 0, err
+	}
+}
+
+// returnVals returns the value of err if it is a *parser.ErrReturn for the
+// current block, like parser.ReturnVals.
+func returnVals(err error) (Value, error) {
+	var ret *parser.ErrReturn
+	if !errors.As(err, &ret) {
+		return nil, err
+	}
+	if ret.Len >= 2 {
+		return nil, &parser.ErrReturn{Len: ret.Len - 1, Value: ret.Value}
+	}
+	return &valueProxy{ret.Value}, nil
+}
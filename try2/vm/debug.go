@@ -0,0 +1,154 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/parser"
+)
+
+// The Debugger of the VM is the one of the interpreter (see parser.Debugger).
+type (
+	Debugger   = parser.Debugger
+	Action     = parser.Action
+	StopReason = parser.StopReason
+	Stop       = parser.Stop
+	Breakpoint = parser.Breakpoint
+)
+
+const (
+	ActionContinue = parser.ActionContinue
+	ActionStepInto = parser.ActionStepInto
+	ActionStepOver = parser.ActionStepOver
+	ActionStepOut  = parser.ActionStepOut
+	ActionQuit     = parser.ActionQuit
+
+	StopPause      = parser.StopPause
+	StopBreakpoint = parser.StopBreakpoint
+	StopStep       = parser.StopStep
+)
+
+// ErrQuit is returned by executing when the Debugger was told to quit.
+var ErrQuit = parser.ErrQuit
+
+// NewDebugger returns a Debugger calling onStop with the paused VM, without
+// breakpoints. The VM can be inspected with VM.Frames until onStop returns.
+// The Debugger does not pause the interpreter.
+func NewDebugger(onStop func(v *VM, stop Stop) Action) *Debugger {
+	return parser.NewDebugger(func(p parser.Paused, stop Stop) Action {
+		if p, ok := p.(paused); ok {
+			return onStop(p.v, stop)
+		}
+		return ActionContinue
+	})
+}
+
+// debugAt pauses at the OpPos which changed the position of the current scope
+// from prev to pos, if v.Debugger says so.
+func (v *VM) debugAt(prev, pos string) error {
+	if v.Debugger == nil {
+		return nil
+	}
+	return v.Debugger.At(paused{v}, parsePos(pos), parsePos(prev), len(v.scopes))
+}
+
+// Frame is a scope of a VM, for debuggers.
+type Frame struct {
+	Note  string
+	Pos   lexer.Position
+	Vars  []Var
+	Args  []parser.Evaler
+	Stack []parser.Evaler
+	// Stack is the value stack, top last.
+}
+
+// Var is a local variable of a Frame.
+type Var struct {
+	Name  string
+	Value parser.Evaler
+}
+
+// Frames returns the scopes of v, latest scope first.
+// Values not set yet are nil.
+func (v *VM) Frames() []Frame {
+	re := make([]Frame, len(v.scopes))
+	for i, s := range v.scopes {
+		f := Frame{Note: s.note, Pos: parsePos(s.pos)}
+		for j, name := range s.varNames {
+			if name == "" {
+				continue
+			}
+			f.Vars = append(f.Vars, Var{Name: name, Value: evalerOf(s.vars[j])})
+		}
+		for _, a := range s.args {
+			f.Args = append(f.Args, evalerOf(a))
+		}
+		for _, v2 := range s.stack {
+			f.Stack = append(f.Stack, evalerOf(v2))
+		}
+		re[len(v.scopes)-1-i] = f
+	}
+	return re
+}
+
+// paused is the parser.Paused of a VM.
+type paused struct{ v *VM }
+
+var _ parser.Paused = paused{}
+
+// Frames returns the scopes of the VM as in VM.Frames.
+// Each has the scopes vars, args, stack (top last) and, for blocks, captured
+// (the variables of the scopes the block was made in).
+func (p paused) Frames() []parser.Frame {
+	re := make([]parser.Frame, len(p.v.scopes))
+	for i, f := range p.v.Frames() {
+		s := p.v.scopes[len(p.v.scopes)-1-i]
+		vars := make([]parser.Var, len(f.Vars))
+		for j, v := range f.Vars {
+			vars[j] = parser.Var{Name: v.Name, Value: v.Value}
+		}
+		args := make([]parser.Var, len(f.Args))
+		for j, a := range f.Args {
+			args[j] = parser.Var{Name: "$" + strconv.Itoa(j), Value: a}
+		}
+		stack := make([]parser.Var, len(f.Stack))
+		for j, v2 := range f.Stack {
+			stack[j] = parser.Var{Name: strconv.Itoa(j), Value: v2}
+		}
+		scopes := []parser.Scope{{Name: "vars", Vars: vars}, {Name: "args", Vars: args}, {Name: "stack", Vars: stack}}
+		if s.sn != nil {
+			captured := make([]parser.Var, 0)
+			for _, vss := range s.sn.matrix {
+				for _, vs := range vss {
+					if vs.name != "" {
+						captured = append(captured, parser.Var{Name: vs.name, Value: evalerOf(vs.v2)})
+					}
+				}
+			}
+			scopes = append(scopes, parser.Scope{Name: "captured", Vars: captured})
+		}
+		re[i] = parser.Frame{Name: f.Note, Pos: f.Pos, Scopes: scopes}
+	}
+	return re
+}
+
+// Eval evaluates src in the scope at index frame of Frames by the interpreter,
+// without pausing. Blocks called by src are executed by the VM.
+func (p paused) Eval(frame int, src string) (parser.Evaler, error) {
+	v := p.v
+	if frame < 0 || frame >= len(v.scopes) {
+		return nil, fmt.Errorf("frame %d out of range (%d scope(s))", frame, len(v.scopes))
+	}
+	d := v.Debugger
+	v.Debugger = nil
+	defer func() { v.Debugger = d }()
+	return parser.EvalDebug(v.scopes[len(v.scopes)-1-frame].iEnv(), src)
+}
+
+func evalerOf(v Value) parser.Evaler {
+	if v == nil {
+		return nil
+	}
+	return v.Evaler()
+}
@@ -1,10 +1,23 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/errs"
+	"gitlab.com/coalang/go-coa/try2/parser"
 )
 
+// ErrStackUnderflow is wrapped by errors of instructions that need more values
+// than the stack has.
+var ErrStackUnderflow = errors.New("stack underflow")
+
+// PanicError is a panic recovered while executing, e.g. in a native.
+type PanicError = parser.PanicError
+
+// ErrorWithTrace is an error while executing, with the scopes at the time it happened.
 type ErrorWithTrace struct {
 	wrapped error
 	trace   []TraceFrame
@@ -14,6 +27,52 @@ func (e *ErrorWithTrace) Unwrap() error {
 	return e.wrapped
 }
 
+// Trace returns the scopes at the time of e, latest scope last.
+func (e *ErrorWithTrace) Trace() []TraceFrame { return e.trace }
+
+// Pos returns the position of the last OpPos executed in the latest scope.
+func (e *ErrorWithTrace) Pos() lexer.Position {
+	if len(e.trace) == 0 {
+		return lexer.Position{}
+	}
+	return parsePos(e.trace[len(e.trace)-1].Pos)
+}
+
+// Source returns the position of the node the instruction executing in the
+// latest scope was compiled from (see compile.Instructions.Pos), which is more
+// precise than Pos. ok is false if unknown.
+func (e *ErrorWithTrace) Source() (pos lexer.Position, ok bool) {
+	if len(e.trace) == 0 || e.trace[len(e.trace)-1].src == nil {
+		return lexer.Position{}, false
+	}
+	return *e.trace[len(e.trace)-1].src, true
+}
+
+// Loc returns the location of the instruction executing in the latest scope, or
+// -1 if unknown.
+func (e *ErrorWithTrace) Loc() int {
+	if len(e.trace) == 0 || e.trace[len(e.trace)-1].loc == nil {
+		return -1
+	}
+	return *e.trace[len(e.trace)-1].loc
+}
+
+var _ errs.Framed = (*ErrorWithTrace)(nil)
+
+// Frames returns the scopes of e as frames, latest scope first. Their positions
+// are of the instructions executing (see Source) if known.
+func (e *ErrorWithTrace) Frames() []errs.ERTFrame {
+	re := make([]errs.ERTFrame, len(e.trace))
+	for i, f := range e.trace {
+		pos := parsePos(f.Pos)
+		if f.src != nil {
+			pos = *f.src
+		}
+		re[len(e.trace)-1-i] = errs.ERTFrame{Pos: pos, Call: f.Note}
+	}
+	return re
+}
+
 func (e *ErrorWithTrace) Error() string {
 	b := new(strings.Builder)
 	b.WriteString("error:\n  ")
@@ -29,6 +88,8 @@ func (e *ErrorWithTrace) Error() string {
 
 type TraceFrame struct {
 	Pos       string // TODO: change to lexer.Position
+	src       *lexer.Position
+	Note      string
 	ctx       []string
 	ctxOffset int
 	loc       *int
@@ -38,6 +99,9 @@ type TraceFrame struct {
 func (t *TraceFrame) String() string {
 	b := new(strings.Builder)
 	b.WriteString(t.Pos)
+	if t.Note != "" {
+		fmt.Fprintf(b, " (%s)", t.Note)
+	}
 	if len(t.ctx) > 0 {
 		b.WriteString("\n     context:\n")
 		for i, inst := range t.ctx {
@@ -55,6 +119,9 @@ func (t *TraceFrame) String() string {
 		b.WriteString("\n     latest loc: ")
 		fmt.Fprintf(b, "%03x", *t.loc)
 	}
+	if t.src != nil {
+		fmt.Fprintf(b, "\n     source: %s", t.src)
+	}
 	if len(t.args) > 0 {
 		b.WriteString("\n     ")
 		b.WriteString(strings.Join(t.args, ", "))
@@ -1,50 +1,267 @@
 package vm
 
 import (
+	"context"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/alecthomas/participle/v2/lexer"
+	"gitlab.com/coalang/go-coa/try2/compile"
 	"gitlab.com/coalang/go-coa/try2/parser"
 	"gitlab.com/coalang/go-coa/try2/util"
 )
 
+// iEnv adapts a Scope to parser.IEnv so natives can be called from the VM.
 func (s *Scope) iEnv() parser.IEnv { return &iEnv{s: s} }
 
 type iEnv struct {
 	s *Scope
 }
 
-func (e *iEnv) Def(string, parser.Evaler)        { panic("unimplemented") }
-func (e *iEnv) Mod(string, parser.Evaler)        { panic("unimplemented") }
-func (e *iEnv) Get(string) (parser.Evaler, bool) { panic("unimplemented") }
-func (e *iEnv) Has(string) bool                  { panic("unimplemented") }
-func (e *iEnv) HasKeys([]string) bool            { panic("unimplemented") }
+var _ parser.IEnv = (*iEnv)(nil)
 
-func (e *iEnv) Dump() *parser.EnvDump { panic("unimplemented") }
+func (e *iEnv) Def(key string, evaler parser.Evaler) {
+	s := e.s
+	if i := s.varIndex(key); i != -1 {
+		s.vars[i] = &valueProxy{evaler}
+	} else {
+		s.vars = append(s.vars, &valueProxy{evaler})
+		s.varNames = append(s.varNames, key)
+	}
+	s.callHooks()
+}
+
+func (e *iEnv) Mod(key string, evaler parser.Evaler) {
+	if p := e.s.parent; p != nil && p.iEnv().Has(key) {
+		p.iEnv().Mod(key, evaler)
+	}
+	e.Def(key, evaler)
+}
+
+func (e *iEnv) Get(key string) (parser.Evaler, bool) {
+	s := e.s
+	if i := s.varIndex(key); i != -1 && s.vars[i] != nil {
+		return s.vars[i].Evaler(), true
+	}
+	if vs, ok := s.sn.lookup(key); ok {
+		return vs.v2.Evaler(), true
+	}
+	if i, ok := s.argIndex(key); ok {
+		return s.args[i].Evaler(), true
+	}
+	if s.lone && !util.IsBuiltin(key) {
+		return nil, false
+	}
+	if s.parent != nil {
+		return s.parent.iEnv().Get(key)
+	}
+	evaler, ok := s.dynvars[key]
+	return evaler, ok
+}
+
+func (e *iEnv) Has(key string) bool {
+	_, ok := e.Get(key)
+	return ok
+}
+
+func (e *iEnv) HasKeys(keys []string) bool {
+	for _, key := range keys {
+		if !e.Has(key) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *iEnv) Dump() *parser.EnvDump {
+	vars := map[string]string{}
+	for i, name := range e.s.varNames {
+		if name == "" || e.s.vars[i] == nil {
+			continue
+		}
+		vars[name] = util.ToInspect(e.s.vars[i].Evaler())
+	}
+	return &parser.EnvDump{
+		Pos:  e.Pos2(),
+		Lone: e.s.lone,
+		Vars: vars,
+	}
+}
+
+func (e *iEnv) AddHook(name string, f parser.Hook) {
+	// the VM runs natives synchronously, so hooks are too
+	if f() {
+		e.s.hooks = append(e.s.hooks, f)
+	}
+}
+
+func (e *iEnv) Keys() []string {
+	keys := e.MyKeys()
+	if e.s.parent != nil {
+		return append(keys, e.s.parent.iEnv().Keys()...)
+	}
+	for key := range e.s.dynvars {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (e *iEnv) MyKeys() []string {
+	keys := make([]string, 0, len(e.s.varNames))
+	for _, name := range e.s.varNames {
+		if name != "" {
+			keys = append(keys, name)
+		}
+	}
+	for i := range e.s.args {
+		keys = append(keys, "$"+strconv.Itoa(i))
+	}
+	return keys
+}
+
+func (e *iEnv) AllowParallel2() bool { return false }
+func (e *iEnv) Debug2() bool         { return false }
+func (e *iEnv) Pos2() lexer.Position { return parsePos(e.s.pos) }
 
-func (e *iEnv) AddHook(string, parser.Hook) { panic("unimplemented") }
+func (e *iEnv) Context() context.Context { return e.s.vm.context() }
+func (e *iEnv) Limits2() *parser.Limits  { return e.s.vm.Limits }
+func (e *iEnv) Stdio2() *parser.Stdio    { return e.s.vm.Stdio }
+func (e *iEnv) StackLen() int            { return len(e.s.vm.scopes) }
 
-func (e *iEnv) Keys() []string   { panic("unimplemented") }
-func (e *iEnv) MyKeys() []string { panic("unimplemented") }
+func (e *iEnv) Deterministic2() *parser.Deterministic { return e.s.vm.Deterministic }
 
-func (e *iEnv) AllowParallel2() bool { panic("unimplemented") }
-func (e *iEnv) Debug2() bool         { panic("unimplemented") }
-func (e *iEnv) Pos2() lexer.Position { return lexer.Position{Filename: e.s.pos} }
+// parsePos parses a position in the format of lexer.Position.String, as stored
+// by OpPos. If pos is not in that format, it is returned as the filename.
+func parsePos(pos string) lexer.Position {
+	parts := strings.Split(pos, ":")
+	if len(parts) < 3 {
+		return lexer.Position{Filename: pos}
+	}
+	line, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return lexer.Position{Filename: pos}
+	}
+	column, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return lexer.Position{Filename: pos}
+	}
+	return lexer.Position{
+		Filename: strings.Join(parts[:len(parts)-2], ":"),
+		Line:     line,
+		Column:   column,
+	}
+}
 
 func (e *iEnv) Printf(format string, v ...interface{}) {
 	log.Printf(format, v...)
 }
 
-func (e *iEnv) CheckResources(rs []util.Resource) bool { panic("unimplemented") }
-func (e *iEnv) BadResources(rs []util.Resource) []int  { panic("unimplemented") }
-func (e *iEnv) LockResources(pos lexer.Position, rs []util.ResourceDef, args []string) {
-	panic("unimplemented")
+func (e *iEnv) CheckResources(rs []util.Resource) bool {
+	return len(e.BadResources(rs)) == 0
+}
+
+func (e *iEnv) BadResources(rs []util.Resource) []int {
+	re := make([]int, 0)
+	rg := e.s.vm.ResourcesGuard
+	if rg == nil {
+		return re
+	}
+	for i, r := range rs {
+		if !rg.Allowed(r) {
+			re = append(re, i)
+		}
+	}
+	return re
+}
+
+func (e *iEnv) LockResources(_ lexer.Position, rs []util.ResourceDef, args []string) {
+	for i, r := range rs {
+		e.s.vm.resourceMutex(r.Name, args[i]).Lock()
+	}
+}
+
+func (e *iEnv) UnlockResources(_ lexer.Position, rs []util.ResourceDef, args []string) {
+	for i, r := range rs {
+		e.s.vm.resourceMutex(r.Name, args[i]).Unlock()
+	}
+}
+
+func (e *iEnv) Inherit(pos lexer.Position) parser.IEnv {
+	return e.s.inherit("Inherit " + pos.String()).iEnv()
 }
-func (e *iEnv) UnlockResources(pos lexer.Position, rs []util.ResourceDef, args []string) {
-	panic("unimplemented")
+
+func (e *iEnv) InheritLone(pos lexer.Position) parser.IEnv {
+	s := e.s.inherit("InheritLone " + pos.String())
+	s.lone = true
+	return s.iEnv()
 }
 
-func (e *iEnv) Inherit(pos lexer.Position) parser.IEnv     { panic("unimplemented") }
-func (e *iEnv) InheritLone(pos lexer.Position) parser.IEnv { panic("unimplemented") }
+// LoadPath compiles the file at path and executes it in this scope.
+func (e *iEnv) LoadPath(path string) (re parser.Evaler, err error) {
+	root, err := parser.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	insts, err := compile.NewEnv(lexer.Position{Filename: path}).NewScope().CompileNodes(*root)
+	if err != nil {
+		return nil, err
+	}
+	v := e.s.vm
+	v.pushScope(e.s)
+	defer v.popScope()
+	err = v.exec(NewProgram(insts))
+	if err != nil {
+		return nil, err
+	}
+	if len(e.s.stack) == 0 {
+		return nil, nil
+	}
+	return v.popFrame().Evaler(), nil
+}
 
-func (e *iEnv) LoadPath(path string) (re parser.Evaler, err error) { panic("unimplemented") }
+// varIndex returns the index of the variable named key in s.vars, or -1.
+func (s *Scope) varIndex(key string) int {
+	for i, name := range s.varNames {
+		if name == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// argIndex returns the index of the argument named key (e.g. $0) in s.args.
+func (s *Scope) argIndex(key string) (int, bool) {
+	if !strings.HasPrefix(key, "$") {
+		return 0, false
+	}
+	i, err := strconv.Atoi(key[1:])
+	if err != nil || i < 0 || i >= len(s.args) {
+		return 0, false
+	}
+	return i, true
+}
+
+func (s *Scope) callHooks() {
+	next := s.hooks[:0]
+	for _, hook := range s.hooks {
+		if hook() {
+			next = append(next, hook)
+		}
+	}
+	s.hooks = next
+}
+
+// resourceMutex returns the mutex for the resource name with argument arg.
+func (v *VM) resourceMutex(name, arg string) *sync.Mutex {
+	v.resourceLock.Lock()
+	defer v.resourceLock.Unlock()
+	if _, ok := v.resources[name]; !ok {
+		v.resources[name] = map[string]*sync.Mutex{}
+	}
+	if _, ok := v.resources[name][arg]; !ok {
+		v.resources[name][arg] = new(sync.Mutex)
+	}
+	return v.resources[name][arg]
+}
@@ -7,6 +7,7 @@ import (
 
 	"gitlab.com/coalang/go-coa/try2/compile"
 	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/util"
 )
 
 type Instructions struct {
@@ -14,9 +15,11 @@ type Instructions struct {
 	offset int
 	insts  []compile.Instruction
 	sn     *scopeSnapshot
+	vm     *VM
 }
 
-func (i *Instructions) Evaler() parser.Evaler { return nil }
+// Evaler returns i as a parser.Callable, so that natives can call it.
+func (i *Instructions) Evaler() parser.Evaler { return &callable{i} }
 
 func (i *Instructions) Run(v *VM) error {
 	return nil
@@ -31,9 +34,12 @@ func (i *Instructions) VMCall(v *VM) (Value, error) {
 	v.s().sn = i.sn
 	err := v.exec(p)
 	if err != nil {
-		return nil, err
+		return returnVals(err)
 	}
 	v.logCurrent()
+	if len(v.s().stack) == 0 {
+		return &valueProxy{}, nil
+	}
 	returned := v.popFrame()
 	log.Println("VMCall returned", returned)
 	return returned, nil
@@ -45,3 +51,34 @@ func (i *Instructions) String() string {
 	fmt.Fprintf(b, "\n%s", i.sn)
 	return b.String()
 }
+
+// callable calls Instructions on the VM it was made in.
+type callable struct{ i *Instructions }
+
+var _ parser.Callable = (*callable)(nil)
+
+func (c *callable) Call(env parser.IEnv, args []parser.Evaler) (parser.Evaler, error) {
+	v := c.i.vm
+	if env, ok := env.(*iEnv); ok {
+		// the native may be running in a strand (see VM.fork)
+		v = env.s.vm
+	}
+	if err := v.checkDepth(); err != nil {
+		return nil, err
+	}
+	v.pushScope(v.s().inherit("native call"))
+	defer v.popScope()
+	v.s().args = proxySlice(args)
+	returned, err := c.i.VMCall(v)
+	if err != nil {
+		return nil, err
+	}
+	return returned.Evaler(), nil
+}
+
+func (c *callable) Info(_ parser.IEnv) util.Info                         { return util.InfoPure }
+func (c *callable) Eval(_ parser.IEnv) (result parser.Evaler, err error) { return c, nil }
+func (c *callable) String() string                                       { return c.i.String() }
+func (c *callable) Inspect() string                                      { return c.i.String() }
+func (c *callable) IDUses() []string                                     { return nil }
+func (c *callable) IDSets() []string                                     { return nil }
@@ -1,13 +1,20 @@
 package vm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/alecthomas/participle/v2/lexer"
 	"gitlab.com/coalang/go-coa/try2/compile"
 	"gitlab.com/coalang/go-coa/try2/parser"
+	"gitlab.com/coalang/go-coa/try2/prof"
+	"gitlab.com/coalang/go-coa/try2/trace"
 )
 
 const ctxLength = 9
@@ -70,12 +77,58 @@ type VM struct {
 	globalProg *Program
 	// globalProg is the program to be executed.
 	// This is mainly used for debugging.used for debugging, etc
+
+	ResourcesGuard parser.ResourcesGuard
+	// ResourcesGuard guards resources used by natives (see parser.Env.ResourcesGuard).
+
+	resources    map[string]map[string]*sync.Mutex
+	resourceLock *sync.Mutex
+
+	ctx context.Context
+	// ctx is the context of the current execution (see ExecuteContext).
+
+	Limits *parser.Limits
+	// Limits limits execution, if not nil (see parser.Host.Limits).
+
+	Stdio *parser.Stdio
+	// Stdio is used by natives instead of os.Stdin, os.Stdout and os.Stderr, if
+	// not nil (see parser.Host.Stdio).
+
+	Profile *prof.Profile
+	// Profile profiles calls, if not nil (see parser.Host.Profile).
+
+	Deterministic *parser.Deterministic
+	// Deterministic makes execution reproducible, if not nil (see
+	// parser.Host.Deterministic).
+	// Strands of bundles are run one at a time instead of in parallel.
+
+	Debugger *Debugger
+	// Debugger pauses execution, if not nil (see parser.Host.Debugger).
+
+	Tracer *trace.Tracer
+	// Tracer traces how strands of bundles are scheduled, if not nil (see
+	// parser.Host.Tracer).
+
+	track trace.Track
+	// track is the track of the goroutine executing, for Tracer.
+
+	frame *prof.Frame
+	// frame is the call being profiled.
+
+	steps *int64
+	// steps is the number of instructions executed, shared with forks.
+
+	forked []Value
+	// forked are the variables of the current scope when forked (see join).
 }
 
 // NewVM makes a new blank VM.
 func NewVM() *VM {
 	return &VM{
-		scopes: make([]*Scope, 0),
+		scopes:       make([]*Scope, 0),
+		resources:    map[string]map[string]*sync.Mutex{},
+		resourceLock: new(sync.Mutex),
+		steps:        new(int64),
 	}
 }
 
@@ -93,11 +146,40 @@ func (v *VM) newScope(note string) *Scope {
 // Execute sets prog as the global program to execute.
 // This cannot be called concurrently.
 func (v *VM) Execute(prog *Program) (err error) {
+	return v.ExecuteContext(context.Background(), prog)
+}
+
+// ExecuteContext is like Execute, but stops with a *parser.CanceledError when ctx
+// is done.
+func (v *VM) ExecuteContext(ctx context.Context, prog *Program) (err error) {
+	v.ctx = ctx
+	v.steps = new(int64)
 	v.globalProg = prog
 	v.pushScope(v.newScope("Execute"))
 	return v.exec(prog)
 }
 
+// context returns the context of the current execution.
+func (v *VM) context() context.Context {
+	if v.ctx == nil {
+		return context.Background()
+	}
+	return v.ctx
+}
+
+// Result returns the value of the last node of the program last executed, or nil
+// if there is none.
+func (v *VM) Result() parser.Evaler {
+	if len(v.scopes) == 0 {
+		return nil
+	}
+	stack := v.s().stack
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[len(stack)-1].Evaler()
+}
+
 // Scope is equivalent to a frame in the call stack.
 type Scope struct {
 	sn *scopeSnapshot
@@ -136,6 +218,15 @@ type Scope struct {
 
 	note string
 	// note is for debugging.
+
+	lone bool
+	// lone is true if variables (except builtins) of parent must not be visible (see parser.Env.InheritLone).
+
+	hooks []parser.Hook
+	// hooks are hooks added by natives through iEnv.
+
+	regions []region
+	// regions stores the loops and inlined bodies currently executing.
 }
 
 func (s *Scope) inherit(note string) *Scope {
@@ -156,9 +247,12 @@ func (v *VM) s() *Scope {
 }
 
 // sLevel returns the scope at level levels above the current scope.
-func (v *VM) sLevel(level int) *Scope {
+func (v *VM) sLevel(level int) (*Scope, error) {
 	log.Println("sLevel i", len(v.scopes)-1-level)
-	return v.scopes[len(v.scopes)-1-level]
+	if level < 0 || level >= len(v.scopes) {
+		return nil, fmt.Errorf("scope level %d out of range (%d scope(s))", level, len(v.scopes))
+	}
+	return v.scopes[len(v.scopes)-1-level], nil
 }
 
 func (v *VM) pushScope(s *Scope) { v.scopes = append(v.scopes, s) }
@@ -174,6 +268,66 @@ func (v *VM) pushFrame(v2 Value) {
 	}
 	v.s().stack = append(v.s().stack, v2)
 }
+
+// need returns an error if the current stack has less than n values.
+func (v *VM) need(n int) error {
+	if len(v.s().stack) < n {
+		return v.wrapError(fmt.Errorf("%w: need %d value(s), have %d", ErrStackUnderflow, n, len(v.s().stack)))
+	}
+	return nil
+}
+
+// step counts an instruction, and returns an error if it exceeds the limit of
+// instructions.
+func (v *VM) step() error {
+	if v.Limits == nil || v.Limits.Instructions == 0 {
+		return nil
+	}
+	if atomic.AddInt64(v.steps, 1) > v.Limits.Instructions {
+		return v.wrapError(&parser.LimitError{Limit: parser.LimitInstructions, Max: v.Limits.Instructions, Pos: parsePos(v.s().pos)})
+	}
+	return nil
+}
+
+// checkDepth returns an error if pushing a scope would exceed the limit of call
+// depth.
+func (v *VM) checkDepth() error {
+	err := v.Limits.CheckCallDepth(parsePos(v.s().pos), len(v.scopes)+1)
+	if err != nil {
+		return v.wrapError(err)
+	}
+	return nil
+}
+
+// checkValue returns an error if evaler exceeds the limits of lists and strings.
+func (v *VM) checkValue(evaler parser.Evaler) error {
+	err := v.Limits.CheckValue(parsePos(v.s().pos), evaler)
+	if err != nil {
+		return v.wrapError(err)
+	}
+	return nil
+}
+
+// enterCall starts profiling a call of the callee named name at the current
+// position, and returns the frame to restore with exitCall.
+func (v *VM) enterCall(name string) (prev *prof.Frame) {
+	if v.Profile == nil {
+		return nil
+	}
+	prev = v.frame
+	v.frame = v.Profile.Enter(prev, prof.Site{Pos: parsePos(v.s().pos), Name: name})
+	return prev
+}
+
+// exitCall stops profiling the current call, and restores prev.
+func (v *VM) exitCall(prev *prof.Frame) {
+	if v.Profile == nil {
+		return
+	}
+	v.frame.Exit()
+	v.frame = prev
+}
+
 func (v *VM) popFrame() Value {
 	v2 := v.s().stack[len(v.s().stack)-1]
 	v.s().stack = v.s().stack[:len(v.s().stack)-1]
@@ -214,7 +368,23 @@ func (v *VM) logCurrent() {
 
 func (v *VM) exec(p *Program) (err error) {
 	// v.pushScope(v.newScope())
+	done := v.context().Done()
+	defer func() {
+		// natives and malformed programs must not crash the host
+		if r := recover(); r != nil {
+			log.Printf("recovered: %v\n%s", r, debug.Stack())
+			err = v.wrapError(&PanicError{Value: r})
+		}
+	}()
 	for i := 0; i < len(p.insts); i++ {
+		select {
+		case <-done:
+			return v.wrapError(&parser.CanceledError{Pos: parsePos(v.s().pos), Err: v.ctx.Err()})
+		default:
+		}
+		if err := v.step(); err != nil {
+			return err
+		}
 		inst := p.insts[i]
 		v.s().latestInst = &inst
 		{
@@ -233,15 +403,29 @@ func (v *VM) exec(p *Program) (err error) {
 		switch inst.Opcode {
 		case compile.OpNop:
 		case compile.OpPos:
-			v.s().pos = inst.B.(string)
+			pos, ok := inst.B.(string)
+			if !ok {
+				return v.wrapError(badOperand(inst))
+			}
+			prev := v.s().pos
+			v.s().pos = pos
+			if err := v.debugAt(prev, pos); err != nil {
+				return v.wrapError(err)
+			}
 		case compile.OpDynVar:
-			name := inst.B.(string)
-			dynvar := v.s().dynvars[name]
+			name, ok := inst.B.(string)
+			if !ok {
+				return v.wrapError(badOperand(inst))
+			}
+			dynvar, ok := v.s().dynvars[name]
+			if !ok {
+				return v.wrapError(fmt.Errorf("builtin %s not found", name))
+			}
 			v.pushFrame(&valueProxy{dynvar})
 		case compile.OpWrap:
-			log.Printf("┌ %s: %s", v.s().pos, inst.B.(string))
+			log.Printf("┌ %s: %v", v.s().pos, inst.B)
 		case compile.OpUnwrap:
-			log.Printf("└ %s: %s", v.s().pos, inst.B.(string))
+			log.Printf("└ %s: %v", v.s().pos, inst.B)
 
 		case compile.OpVarDeclare:
 			log.Printf("declared %d on level %d", inst.A, len(v.scopes)-1)
@@ -255,34 +439,69 @@ func (v *VM) exec(p *Program) (err error) {
 		case compile.OpVarReassign, compile.OpVarAssign:
 			v.logInst(p, i, inst)
 			log.Println("assign, current vars:", v.s().vars)
+			name, ok := inst.B.(string)
+			if !ok {
+				return v.wrapError(badOperand(inst))
+			}
+			if inst.A < 0 || inst.A >= len(v.s().vars) {
+				return v.wrapError(fmt.Errorf("variable %d out of range (%d declared)", inst.A, len(v.s().vars)))
+			}
+			if err := v.need(1); err != nil {
+				return err
+			}
 			v.s().vars[inst.A] = v.popFrame()
-			v.s().varNames[inst.A] = inst.B.(string)
+			v.s().varNames[inst.A] = name
 		case compile.OpVarLoad:
-			index, level := inst.A, inst.B.(int)
+			index := inst.A
+			level, ok := inst.B.(int)
+			if !ok {
+				return v.wrapError(badOperand(inst))
+			}
 			s := v.s()
 			var v2 Value
 			var name string
 			if s.sn != nil && level != 0 {
-				vs := s.sn.get(level, index)
+				vs, err := s.sn.get(level, index)
+				if err != nil {
+					return v.wrapError(err)
+				}
 				v2 = vs.v2
 				name = vs.name
 			} else {
-				s := v.sLevel(level)
-				v2 = s.vars[inst.A]
-				if err := v.nilCheck("loaded nil var", v2); err != nil {
-					return err
+				s, err := v.sLevel(level)
+				if err != nil {
+					return v.wrapError(err)
 				}
-				name = s.varNames[inst.A]
+				if index < 0 || index >= len(s.vars) {
+					return v.wrapError(fmt.Errorf("variable %d on level %d out of range (%d declared)", index, level, len(s.vars)))
+				}
+				v2 = s.vars[index]
+				name = s.varNames[index]
+			}
+			if err := v.nilCheck("loaded nil var "+name, v2); err != nil {
+				return err
 			}
 			v.pushFrame(v2)
 			log.Printf("loaded %s: %v", name, v2)
 		case compile.OpArgLoad:
 			v.logCurrent()
+			if inst.A < 0 || inst.A >= len(v.s().args) {
+				return v.wrapError(fmt.Errorf("argument $%d not given (%d given)", inst.A, len(v.s().args)))
+			}
+			if err := v.nilCheck("loaded nil argument", v.s().args[inst.A]); err != nil {
+				return err
+			}
 			v.pushFrame(v.s().args[inst.A])
 
 		case compile.OpCall:
 			log.Println("======OpCall1======")
 			uses := inst.A
+			if uses < 1 {
+				return v.wrapError(badOperand(inst))
+			}
+			if err := v.need(uses); err != nil {
+				return err
+			}
 			// stack:
 			// other things
 			// callee
@@ -307,46 +526,27 @@ func (v *VM) exec(p *Program) (err error) {
 			log.Println("======OpCall3====== post", callee)
 			v.logCurrent()
 
-			switch callee := callee.(type) {
-			case VMCallable:
-				var returned Value
-				err := func() error {
-					v.pushScope(v.s().inherit("VMCall"))
-					defer v.popScope()
-					// TODO: reset stack
-					v.s().args = args
-					v.logCurrent()
-					log.Println("============VMCall============")
-					returned, err = callee.VMCall(v)
-					if err != nil {
-						return err
-					}
-					return nil
-				}()
-				if err != nil {
-					return v.wrapError(err)
-				}
-				v.pushFrame(returned)
-			default:
-				log.Println("======OpCall3a======", callee)
-				callable, ok := callee.Evaler().(parser.Callable)
-				if !ok {
-					return v.wrapError(fmt.Errorf("%s is not callable", callee))
-				}
-				log.Printf("calling %s with %s", callee, args)
-				result, err := callable.Call(v.s().iEnv(), unproxySlice(args))
-				if err != nil {
-					return v.wrapError(fmt.Errorf("calling: %w", err))
+			name, _ := inst.B.(string)
+			prev := v.enterCall(name)
+			returned, err := v.call(callee, args)
+			v.exitCall(prev)
+			if err != nil {
+				if to, err := v.s().control(err); err == nil {
+					i = to - 1
+					continue
 				}
-				v.pushFrame(&valueProxy{result})
+				return v.wrapError(err)
 			}
+			if err := v.checkValue(returned.Evaler()); err != nil {
+				return err
+			}
+			v.pushFrame(returned)
 			log.Println("======OpCall4======")
 			v.logCurrent()
 
 		case compile.OpBlockStart:
 			// 1. check validity of block
-			endInst := p.insts[i+inst.A-1]
-			if endInst.Opcode != compile.OpBlockEnd {
+			if inst.A < 2 || i+inst.A-1 >= len(p.insts) || p.insts[i+inst.A-1].Opcode != compile.OpBlockEnd {
 				return v.wrapError(fmt.Errorf("block end not found"))
 			}
 
@@ -373,34 +573,197 @@ func (v *VM) exec(p *Program) (err error) {
 			// NOTE: p.insts includes Os and Oe, strip them off for Instructions
 			log.Println("new block")
 			v.logCurrent()
-			block := Instructions{v.s().pos, i + 1, innerInsts, sn}
+			block := Instructions{v.s().pos, p.offset + i + 1, innerInsts, sn, v}
 			// NOTE: not using the key: value format for struct because this part should define everything in Instructions (at least for now)
 			v.pushFrame(&block)
 			log.Printf("block %x → %x", i, i+inst.A)
 			i += inst.A
 			v.logCurrent()
 		case compile.OpBlockEnd:
-			panic("should be skipped")
+			// skipped by OpBlockStart
+			return v.wrapError(errors.New("block end without block start"))
+
+		case compile.OpBundleStart:
+			b, err := parseBundle(p, i)
+			if err != nil {
+				return v.wrapError(err)
+			}
+			values, err := v.runBundle(b)
+			if err != nil {
+				if to, err := v.s().control(err); err == nil {
+					i = to - 1
+					continue
+				}
+				return v.wrapError(err)
+			}
+			for _, v2 := range values {
+				v.pushFrame(v2)
+			}
+			i = b.end
+		case compile.OpStrandStart, compile.OpStrandTodo, compile.OpStrandReverseDeps,
+			compile.OpStrandInvoke, compile.OpStrandEnd, compile.OpBundleEnd:
+			return v.wrapError(fmt.Errorf("%s outside of bundle", inst.Opcode.Name()))
+
+		case compile.OpMakeList:
+			if err := v.need(inst.A); err != nil {
+				return err
+			}
+			s := v.s()
+			baseI := len(s.stack) - inst.A
+			nodes := make([]parser.Node, inst.A)
+			for j, v2 := range s.stack[baseI:] {
+				nodes[j] = parser.Node{Evaler: v2.Evaler()}
+			}
+			s.stack = s.stack[:baseI]
+			var l parser.Evaler = &parser.List{Content: parser.Nodes{Content: nodes}}
+			if o, _ := inst.B.(string); o == "[m" {
+				l, err = (&parser.List{O: o, Content: parser.Nodes{Content: nodes}}).Eval(s.iEnv())
+				if err != nil {
+					return v.wrapError(err)
+				}
+			}
+			if err := v.checkValue(l); err != nil {
+				return err
+			}
+			v.pushFrame(&valueProxy{l})
+
+		case compile.OpPop:
+			if err := v.need(inst.A); err != nil {
+				return err
+			}
+			s := v.s()
+			s.stack = s.stack[:len(s.stack)-inst.A]
+		case compile.OpLit:
+			if inst.B != nil {
+				return v.wrapError(fmt.Errorf("unsupported literal %v", inst.B))
+			}
+			v.pushFrame(&valueProxy{})
+		case compile.OpListAppend:
+			if err := v.need(2); err != nil {
+				return err
+			}
+			v2 := v.popFrame()
+			l, ok := v.s().stack[len(v.s().stack)-1].Evaler().(*parser.List)
+			if !ok {
+				return v.wrapError(errors.New("appending to non-list"))
+			}
+			l.Content.Content = append(l.Content.Content, parser.Node{Evaler: v2.Evaler()})
+			if err := v.checkValue(l); err != nil {
+				return err
+			}
+
+		case compile.OpJump:
+			i += inst.A - 1
+		case compile.OpJumpUnless:
+			if err := v.need(1); err != nil {
+				return err
+			}
+			b, err := parser.BoolFromEvaler(v.popFrame().Evaler())
+			if err != nil {
+				return v.wrapError(err)
+			}
+			if !b {
+				i += inst.A - 1
+			}
+		case compile.OpLoopStart, compile.OpBodyStart:
+			s := v.s()
+			r := region{loop: inst.Opcode == compile.OpLoopStart, end: i + inst.A, height: len(s.stack)}
+			if r.loop {
+				cont, ok := inst.B.(int)
+				if !ok {
+					return v.wrapError(badOperand(inst))
+				}
+				r.cont = i + cont
+			}
+			s.regions = append(s.regions, r)
+		case compile.OpLoopEnd, compile.OpBodyEnd:
+			s := v.s()
+			if len(s.regions) == 0 {
+				return v.wrapError(fmt.Errorf("%s without start", inst.Opcode.Name()))
+			}
+			s.regions = s.regions[:len(s.regions)-1]
+		case compile.OpBreak, compile.OpContinue, compile.OpReturn:
+			var ctl error
+			switch inst.Opcode {
+			case compile.OpBreak:
+				ctl = parser.ErrBreak
+			case compile.OpContinue:
+				ctl = parser.ErrContinue
+			case compile.OpReturn:
+				if err := v.need(1); err != nil {
+					return err
+				}
+				ctl = &parser.ErrReturn{Len: inst.A, Value: v.popFrame().Evaler()}
+			}
+			to, err := v.s().control(ctl)
+			if err != nil {
+				return v.wrapError(err)
+			}
+			i = to - 1
 
 		case compile.OpLitNumber:
-			b := parser.Number(inst.B.(float64))
+			n, ok := inst.B.(float64)
+			if !ok {
+				return v.wrapError(badOperand(inst))
+			}
+			b := parser.Number(n)
 			v.pushFrame(&valueProxy{&b})
 		case compile.OpLitString:
-			b := parser.String{Content: inst.B.(string)}
+			str, ok := inst.B.(string)
+			if !ok {
+				return v.wrapError(badOperand(inst))
+			}
+			b := parser.String{Content: str}
 			v.pushFrame(&valueProxy{&b})
 		case compile.OpLitRune:
-			b := parser.Rune(inst.B.(rune))
+			r, ok := inst.B.(rune)
+			if !ok {
+				return v.wrapError(badOperand(inst))
+			}
+			b := parser.Rune(r)
 			v.pushFrame(&valueProxy{&b})
 
 		default:
-			return fmt.Errorf("unknown opcode: %s", inst.Opcode.Full())
+			return v.wrapError(fmt.Errorf("unknown opcode: %s", inst.Opcode.Full()))
 		}
 	}
 	return nil
 }
 
+// call calls callee with args (see OpCall).
+func (v *VM) call(callee Value, args []Value) (Value, error) {
+	switch callee := callee.(type) {
+	case VMCallable:
+		if err := v.checkDepth(); err != nil {
+			return nil, err
+		}
+		v.pushScope(v.s().inherit("VMCall"))
+		defer v.popScope()
+		// TODO: reset stack
+		v.s().args = args
+		v.logCurrent()
+		log.Println("============VMCall============")
+		return callee.VMCall(v)
+	default:
+		log.Println("======OpCall3a======", callee)
+		callable, ok := callee.Evaler().(parser.Callable)
+		if !ok {
+			return nil, fmt.Errorf("%s is not callable", callee)
+		}
+		log.Printf("calling %s with %s", callee, args)
+		result, err := callable.Call(v.s().iEnv(), unproxySlice(args))
+		if err != nil {
+			return nil, fmt.Errorf("calling: %w", err)
+		}
+		return &valueProxy{result}, nil
+	}
+}
+
 func (s *Scope) eval(v Value) (Value, error) {
 	log.Println("v", v)
+	if _, ok := v.(VMCallable); ok {
+		return v, nil
+	}
 	if e := v.Evaler(); e != nil {
 		r, err := e.Eval(s.iEnv())
 		if err != nil {
@@ -412,7 +775,7 @@ func (s *Scope) eval(v Value) (Value, error) {
 }
 
 func (s *Scope) wrapError(err error) error {
-	return fmt.Errorf("%s: %s", s.pos, err)
+	return fmt.Errorf("%s: %w", s.pos, err)
 }
 
 func (v *VM) trace() (trace []TraceFrame) {
@@ -429,9 +792,13 @@ func (v *VM) trace() (trace []TraceFrame) {
 
 		var ctx []string
 		var ctxOffset int
-		if loc != nil {
+		if loc != nil && v.globalProg != nil {
 			ctx = make([]string, ctxLength)
 			ctxOffset = -len(ctx) / 2
+			if *loc+ctxOffset < 0 {
+				// start of program
+				ctxOffset = -*loc
+			}
 			for i := range ctx {
 				gp := v.globalProg
 				j := *loc + i + ctxOffset
@@ -445,8 +812,17 @@ func (v *VM) trace() (trace []TraceFrame) {
 			}
 		}
 
+		var src *lexer.Position
+		if s.latestInst != nil {
+			if pos, ok := s.latestInst.Pos(); ok {
+				src = &pos
+			}
+		}
+
 		trace[i] = TraceFrame{
 			Pos:       s.pos,
+			src:       src,
+			Note:      s.note,
 			ctxOffset: ctxOffset,
 			ctx:       ctx,
 			loc:       loc,
@@ -458,11 +834,17 @@ func (v *VM) trace() (trace []TraceFrame) {
 
 func (v *VM) wrapError(err error) *ErrorWithTrace {
 	if err2, ok := err.(*ErrorWithTrace); ok {
-		err = err2.wrapped // NOTE: update (replace) trace
+		// NOTE: keep the trace from where the error happened, which has more scopes
+		return err2
 	}
 	return &ErrorWithTrace{wrapped: err, trace: v.trace()}
 }
 
+// badOperand returns an error for an instruction whose operand B is invalid.
+func badOperand(inst compile.Instruction) error {
+	return fmt.Errorf("%s: invalid operand %v (%T)", inst.Opcode.Name(), inst.B, inst.B)
+}
+
 func (v *VM) nilCheck(reason string, v2 Value) error {
 	if v2 == nil {
 		return v.wrapError(errors.New(reason))
@@ -479,14 +861,25 @@ func (v *VM) scopeSnapshot(insts []compile.Instruction) (*scopeSnapshot, error)
 			log.Printf("2ignoring %s", &inst)
 			continue
 		}
-		index, level := inst.A, inst.B.(int)
+		index := inst.A
+		level, ok := inst.B.(int)
+		if !ok {
+			return nil, badOperand(inst)
+		}
 		if level == 0 {
 			log.Printf("ignoring %s", &inst)
 			continue
 		}
 		log.Printf("adding %s", &inst)
-		name := v.sLevel(level).varNames[index]
-		v2 := v.sLevel(level).vars[index]
+		s, err := v.sLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= len(s.vars) {
+			return nil, fmt.Errorf("variable %d on level %d out of range (%d declared)", index, level, len(s.vars))
+		}
+		name := s.varNames[index]
+		v2 := s.vars[index]
 		vs := varSnapshot{name: name, v2: v2}
 		sn.set(level, index, vs)
 		log.Println("snapshot", vs)
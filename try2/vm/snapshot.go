@@ -28,8 +28,26 @@ func (s *scopeSnapshot) set(level, index int, vs varSnapshot) {
 	s.matrix[level][index] = vs
 }
 
-func (s *scopeSnapshot) get(level, index int) varSnapshot {
-	return s.matrix[level][index]
+func (s *scopeSnapshot) get(level, index int) (varSnapshot, error) {
+	if level >= len(s.matrix) || index >= len(s.matrix[level]) {
+		return varSnapshot{}, fmt.Errorf("variable %d on level %d not in snapshot", index, level)
+	}
+	return s.matrix[level][index], nil
+}
+
+// lookup returns the snapshotted variable named name.
+func (s *scopeSnapshot) lookup(name string) (varSnapshot, bool) {
+	if s == nil {
+		return varSnapshot{}, false
+	}
+	for _, vss := range s.matrix {
+		for _, vs := range vss {
+			if vs.name == name && vs.v2 != nil {
+				return vs, true
+			}
+		}
+	}
+	return varSnapshot{}, false
 }
 
 func (s *scopeSnapshot) String() string {